@@ -6,11 +6,26 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"email-tracking-server/internal/api"
+	"email-tracking-server/internal/apikeys"
+	"email-tracking-server/internal/approval"
+	"email-tracking-server/internal/audit"
+	"email-tracking-server/internal/auth"
+	"email-tracking-server/internal/blobstore"
 	"email-tracking-server/internal/client"
+	"email-tracking-server/internal/emailhtml"
+	"email-tracking-server/internal/events"
+	"email-tracking-server/internal/jwks"
+	"email-tracking-server/internal/metrics"
+	"email-tracking-server/internal/preferences"
+	"email-tracking-server/internal/senders"
+	"email-tracking-server/internal/suppression"
+	"email-tracking-server/internal/templates"
+	"email-tracking-server/internal/webhooks"
 	"email-tracking-server/pkg/logger"
 
 	"github.com/gorilla/mux"
@@ -19,8 +34,9 @@ import (
 
 type Config struct {
 	Server struct {
-		Port string `yaml:"port"`
-		Host string `yaml:"host"`
+		Port      string `yaml:"port"`
+		Host      string `yaml:"host"`
+		PublicURL string `yaml:"public_url"`
 	} `yaml:"server"`
 	Temporal struct {
 		HostPort  string `yaml:"host_port"`
@@ -28,12 +44,43 @@ type Config struct {
 		TaskQueue string `yaml:"task_queue"`
 	} `yaml:"temporal"`
 	JWT struct {
-		Secret string `yaml:"secret"`
+		Secret     string `yaml:"secret"`
+		JWKSURL    string `yaml:"jwks_url"`
+		AllowHS256 bool   `yaml:"allow_hs256"`
+		Issuer     string `yaml:"issuer"`
+		Audience   string `yaml:"audience"`
 	} `yaml:"jwt"`
+	Approvals struct {
+		TokenIssuer  string   `yaml:"token_issuer"`
+		TokenTTL     string   `yaml:"token_ttl"`
+		KeyID        string   `yaml:"key_id"`
+		Secret       string   `yaml:"secret"`
+		PreviousKeys []string `yaml:"previous_keys"`
+	} `yaml:"approvals"`
 	Logging struct {
-		Level  string `yaml:"level"`
-		Format string `yaml:"format"`
+		Level            string   `yaml:"level"`
+		Format           string   `yaml:"format"`
+		DisableRedaction bool     `yaml:"disable_redaction"`
+		SensitiveKeys    []string `yaml:"sensitive_keys"`
+		HashKey          string   `yaml:"hash_key"`
 	} `yaml:"logging"`
+	Sanitizer   emailhtml.PolicyConfig `yaml:"sanitizer"`
+	Attachments struct {
+		Dir string `yaml:"dir"`
+	} `yaml:"attachments"`
+	Internal struct {
+		Token string `yaml:"token"`
+	} `yaml:"internal"`
+	Webhooks struct {
+		ResendSecret string `yaml:"resend_secret"`
+	} `yaml:"webhooks"`
+	Privacy struct {
+		HashKey string `yaml:"hash_key"`
+	} `yaml:"privacy"`
+	Metrics struct {
+		Enabled bool   `yaml:"enabled"`
+		Port    string `yaml:"port"`
+	} `yaml:"metrics"`
 }
 
 func main() {
@@ -45,9 +92,38 @@ func main() {
 	}
 
 	// Initialize logger
-	log := logger.New(config.Logging.Level, config.Logging.Format)
+	log := logger.NewWithRedaction(config.Logging.Level, config.Logging.Format,
+		config.Logging.Format == "json" && !config.Logging.DisableRedaction, config.Logging.SensitiveKeys,
+		firstNonEmpty(config.Logging.HashKey, os.Getenv("LOG_HASH_KEY"), config.JWT.Secret))
 	log.Info("Starting email tracking server")
 
+	// The secret signs the server's own approval, tracking and verification
+	// tokens, so it is required even when user tokens are verified by JWKS
+	if config.JWT.Secret == "" {
+		log.Error("JWT secret not configured; set jwt.secret or JWT_SECRET")
+		os.Exit(1)
+	}
+
+	// User tokens: RS256/ES256 against the JWKS when configured, HS256 with
+	// the shared secret only when explicitly allowed
+	var userKeys *jwks.KeySet
+	allowHS256 := config.JWT.AllowHS256 || os.Getenv("JWT_ALLOW_HS256") == "true"
+	if jwksURL := firstNonEmpty(config.JWT.JWKSURL, os.Getenv("JWT_JWKS_URL")); jwksURL != "" {
+		userKeys, err = jwks.NewKeySet(jwksURL, 15*time.Minute)
+		if err != nil {
+			log.Error("Failed to load JWKS", "error", err)
+			os.Exit(1)
+		}
+	} else if !allowHS256 {
+		log.Error("No way to verify user tokens; set jwt.jwks_url (JWT_JWKS_URL), or jwt.allow_hs256 (JWT_ALLOW_HS256=true) to accept HS256 tokens signed with the JWT secret")
+		os.Exit(1)
+	} else {
+		log.Warn("JWKS not configured; accepting HS256 user tokens signed with the shared JWT secret")
+	}
+	userTokens := api.NewUserTokenVerifier(userKeys, config.JWT.Secret, allowHS256,
+		firstNonEmpty(config.JWT.Issuer, os.Getenv("JWT_ISSUER")),
+		firstNonEmpty(config.JWT.Audience, os.Getenv("JWT_AUDIENCE")))
+
 	// Initialize Temporal client
 	temporalClient, err := client.NewTemporalClient(client.Config{
 		HostPort:  config.Temporal.HostPort,
@@ -59,11 +135,49 @@ func main() {
 	}
 	defer temporalClient.Close()
 
-	// Initialize API handler
-	apiHandler := api.NewEmailHandler(temporalClient, config.Temporal.TaskQueue, config.JWT.Secret, log)
+	// Initialize API handlers
+	templateRegistry := templates.NewRegistry()
+	sanitizer := emailhtml.NewPolicy(config.Sanitizer)
+	blobs, err := blobstore.NewFileStore(firstNonEmpty(config.Attachments.Dir, os.Getenv("ATTACHMENT_STORE_DIR"), "data/attachments"))
+	if err != nil {
+		log.Error("Failed to initialize attachment store", "error", err)
+		os.Exit(1)
+	}
+	senderStore := senders.NewStore()
+	eventLog := events.NewLog()
+	apiKeyStore := apikeys.NewStore()
+	approvals, err := newApprovalSigner(config)
+	if err != nil {
+		log.Error("Failed to configure approval tokens", "error", err)
+		os.Exit(1)
+	}
+
+	apiHandler := api.NewEmailHandler(temporalClient, config.Temporal.TaskQueue, config.JWT.Secret, approvals, templateRegistry, sanitizer, blobs, senderStore, eventLog, userTokens, apiKeyStore, log)
+	templateHandler := api.NewTemplateHandler(templateRegistry, sanitizer, log)
+	publicURL := firstNonEmpty(config.Server.PublicURL, os.Getenv("GO_EMAIL_SERVER_BASE_URL"), "https://tengine.zendwise.work")
+	senderHandler := api.NewSenderHandler(senderStore, temporalClient, config.Temporal.TaskQueue, config.JWT.Secret, publicURL, log)
+
+	var webhookVerifier *webhooks.Verifier
+	if secret := firstNonEmpty(config.Webhooks.ResendSecret, os.Getenv("RESEND_WEBHOOK_SECRET")); secret != "" {
+		if webhookVerifier, err = webhooks.NewVerifier(secret); err != nil {
+			log.Error("Failed to initialize webhook verifier", "error", err)
+			os.Exit(1)
+		}
+	} else {
+		log.Warn("Resend webhook secret not configured; /webhooks/resend is disabled")
+	}
+	suppressionStore := suppression.NewStore()
+	suppressionHandler := api.NewSuppressionHandler(suppressionStore, log)
+	webhookHandler := api.NewWebhookHandler(apiHandler, suppressionStore, webhookVerifier, log)
+	unsubscribeHandler := api.NewUnsubscribeHandler(apiHandler, suppressionStore, eventLog, config.JWT.Secret, log)
+	preferenceStore := preferences.NewStore()
+	preferencesHandler := api.NewPreferencesHandler(apiHandler, preferenceStore, eventLog, config.JWT.Secret, log)
+	apiKeyHandler := api.NewAPIKeyHandler(apiKeyStore, log)
+	privacyHandler := api.NewPrivacyHandler(apiHandler, suppressionStore, preferenceStore, audit.NewLog(firstNonEmpty(config.Privacy.HashKey, os.Getenv("PRIVACY_HASH_KEY"), config.JWT.Secret)), log)
 
 	// Setup routes
 	router := mux.NewRouter()
+	router.Use(auth.RequestID, metrics.Middleware)
 
 	// Health check endpoint
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -72,19 +186,77 @@ func main() {
 		fmt.Fprintf(w, `{"status":"healthy","temporal":"connected","time":"%s"}`, time.Now().UTC().Format(time.RFC3339))
 	}).Methods("GET")
 
-	// Public approval endpoint (no JWT; token-based)
-	router.HandleFunc("/approve-email", apiHandler.ApproveEmail).Methods("GET")
+	// Public approval endpoint (no JWT; token-based; GET confirms, POST approves)
+	router.HandleFunc("/approve-email", apiHandler.ApproveEmail).Methods("GET", "POST")
+
+	// Public open tracking pixel (token-based)
+	router.HandleFunc("/t/o/{token}.gif", apiHandler.TrackOpen).Methods("GET")
+
+	// Public click tracking redirect (token-based)
+	router.HandleFunc("/t/c/{token}", apiHandler.TrackClick).Methods("GET")
+
+	// Public sender verification endpoint (no JWT; token-based)
+	router.HandleFunc("/verify-sender", senderHandler.VerifySender).Methods("GET", "POST")
+
+	// Public unsubscribe endpoint (token-based; POST is RFC 8058 one-click)
+	router.HandleFunc("/unsubscribe", unsubscribeHandler.Unsubscribe).Methods("GET", "POST")
+
+	// Public preference center (token-based)
+	router.HandleFunc("/preferences", preferencesHandler.Preferences).Methods("GET", "POST")
+
+	// Provider webhooks (signature-verified)
+	router.HandleFunc("/webhooks/resend", webhookHandler.ResendWebhook).Methods("POST")
+
+	// Internal routes for the worker (shared token)
+	internalRouter := router.PathPrefix("/internal").Subrouter()
+	internalToken := firstNonEmpty(config.Internal.Token, os.Getenv("INTERNAL_API_TOKEN"))
+	if internalToken == "" {
+		log.Warn("Internal API token not configured; /internal is disabled and the worker will not start. Set INTERNAL_API_TOKEN (or internal.token), see config/.env.example")
+	}
+	internalRouter.Use(api.InternalAuth(internalToken))
+	internalRouter.HandleFunc("/email-tracking/{id}/events", apiHandler.RecordWorkflowEvent).Methods("POST")
+	internalRouter.HandleFunc("/suppressions/check", suppressionHandler.CheckSuppressions).Methods("POST")
+	internalRouter.HandleFunc("/preferences/check", preferencesHandler.CheckPreference).Methods("POST")
 
 	// API routes (protected)
 	apiRouter := router.PathPrefix("/api").Subrouter()
-	apiRouter.Use(apiHandler.JWTMiddleware)
+	apiRouter.Use(apiHandler.JWTMiddleware, api.Authorize)
 
 	apiRouter.HandleFunc("/email-tracking", apiHandler.CreateEmailTracking).Methods("POST")
 	apiRouter.HandleFunc("/email-tracking", apiHandler.GetEmailTrackings).Methods("GET")
+	apiRouter.HandleFunc("/email-tracking/test-send", apiHandler.TestSendEmail).Methods("POST")
 	apiRouter.HandleFunc("/email-tracking/{id}", apiHandler.GetEmailTracking).Methods("GET")
+	apiRouter.HandleFunc("/email-tracking/{id}/events", apiHandler.GetEmailTrackingEvents).Methods("GET")
 	apiRouter.HandleFunc("/email-tracking/{id}", apiHandler.UpdateEmailTracking).Methods("PUT")
 	apiRouter.HandleFunc("/email-tracking/{id}", apiHandler.DeleteEmailTracking).Methods("DELETE")
 
+	apiRouter.HandleFunc("/templates", templateHandler.CreateTemplate).Methods("POST")
+	apiRouter.HandleFunc("/templates", templateHandler.GetTemplates).Methods("GET")
+	apiRouter.HandleFunc("/templates/preview", templateHandler.PreviewTemplate).Methods("POST")
+	apiRouter.HandleFunc("/templates/{id}", templateHandler.GetTemplate).Methods("GET")
+	apiRouter.HandleFunc("/templates/{id}", templateHandler.DeleteTemplate).Methods("DELETE")
+	apiRouter.HandleFunc("/templates/{id}/versions", templateHandler.CreateTemplateVersion).Methods("POST")
+	apiRouter.HandleFunc("/templates/{id}/versions/{version}", templateHandler.GetTemplateVersion).Methods("GET")
+	apiRouter.HandleFunc("/templates/{id}/publish", templateHandler.PublishTemplate).Methods("POST")
+
+	apiRouter.HandleFunc("/suppressions", suppressionHandler.GetSuppressions).Methods("GET")
+	apiRouter.HandleFunc("/suppressions", suppressionHandler.CreateSuppression).Methods("POST")
+	apiRouter.HandleFunc("/suppressions/{email}", suppressionHandler.DeleteSuppression).Methods("DELETE")
+
+	apiRouter.HandleFunc("/privacy/export", privacyHandler.ExportSubject).Methods("POST")
+	apiRouter.HandleFunc("/privacy/erase", privacyHandler.EraseSubject).Methods("POST")
+	apiRouter.HandleFunc("/privacy/audit", privacyHandler.GetAuditTrail).Methods("GET")
+
+	apiRouter.HandleFunc("/api-keys", apiKeyHandler.CreateAPIKey).Methods("POST")
+	apiRouter.HandleFunc("/api-keys", apiKeyHandler.GetAPIKeys).Methods("GET")
+	apiRouter.HandleFunc("/api-keys/{id}", apiKeyHandler.RevokeAPIKey).Methods("DELETE")
+
+	apiRouter.HandleFunc("/sender-identities", senderHandler.CreateSenderIdentity).Methods("POST")
+	apiRouter.HandleFunc("/sender-identities", senderHandler.GetSenderIdentities).Methods("GET")
+	apiRouter.HandleFunc("/sender-identities/{id}", senderHandler.DeleteSenderIdentity).Methods("DELETE")
+	apiRouter.HandleFunc("/sender-identities/{id}/resend-verification", senderHandler.ResendVerification).Methods("POST")
+	apiRouter.HandleFunc("/sender-identities/{id}/default", senderHandler.SetDefaultSender).Methods("POST")
+
 	// Setup server
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%s", config.Server.Host, config.Server.Port),
@@ -94,6 +266,18 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	// Prometheus metrics on their own port, off the public API
+	var metricsServer *http.Server
+	if config.Metrics.Enabled || os.Getenv("ENABLE_METRICS") == "true" {
+		metricsServer = metrics.NewServer(":" + firstNonEmpty(config.Metrics.Port, os.Getenv("METRICS_PORT"), "9090"))
+		go func() {
+			log.Info("Starting metrics server", "addr", metricsServer.Addr)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error("Metrics server failed", "error", err)
+			}
+		}()
+	}
+
 	// Start server in a goroutine
 	go func() {
 		log.Info("Starting HTTP server",
@@ -121,6 +305,9 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	if metricsServer != nil {
+		metricsServer.Shutdown(ctx)
+	}
 	if err := server.Shutdown(ctx); err != nil {
 		log.Error("Server forced to shutdown", "error", err)
 		os.Exit(1)
@@ -158,11 +345,13 @@ func loadConfigFromFile(filename string) (*Config, error) {
 func loadConfigFromEnv() *Config {
 	return &Config{
 		Server: struct {
-			Port string `yaml:"port"`
-			Host string `yaml:"host"`
+			Port      string `yaml:"port"`
+			Host      string `yaml:"host"`
+			PublicURL string `yaml:"public_url"`
 		}{
-			Port: getEnvOrDefault("PORT", "8095"),
-			Host: getEnvOrDefault("HOST", "0.0.0.0"),
+			Port:      getEnvOrDefault("PORT", "8095"),
+			Host:      getEnvOrDefault("HOST", "0.0.0.0"),
+			PublicURL: getEnvOrDefault("GO_EMAIL_SERVER_BASE_URL", "https://tengine.zendwise.work"),
 		},
 		Temporal: struct {
 			HostPort  string `yaml:"host_port"`
@@ -174,16 +363,30 @@ func loadConfigFromEnv() *Config {
 			TaskQueue: getEnvOrDefault("TEMPORAL_TASK_QUEUE", "email-task-queue"),
 		},
 		JWT: struct {
-			Secret string `yaml:"secret"`
+			Secret     string `yaml:"secret"`
+			JWKSURL    string `yaml:"jwks_url"`
+			AllowHS256 bool   `yaml:"allow_hs256"`
+			Issuer     string `yaml:"issuer"`
+			Audience   string `yaml:"audience"`
 		}{
-			Secret: getEnvOrDefault("JWT_SECRET", "Cvgii9bYKF1HtfD8TODRyZFTmFP4vu70oR59YrjGVpS2fXzQ41O3UPRaR8u9uAqNhwK5ZxZPbX5rAOlMrqe8ag=="),
+			Secret:     os.Getenv("JWT_SECRET"),
+			JWKSURL:    os.Getenv("JWT_JWKS_URL"),
+			AllowHS256: getEnvOrDefault("JWT_ALLOW_HS256", "false") == "true",
+			Issuer:     os.Getenv("JWT_ISSUER"),
+			Audience:   os.Getenv("JWT_AUDIENCE"),
 		},
 		Logging: struct {
-			Level  string `yaml:"level"`
-			Format string `yaml:"format"`
+			Level            string   `yaml:"level"`
+			Format           string   `yaml:"format"`
+			DisableRedaction bool     `yaml:"disable_redaction"`
+			SensitiveKeys    []string `yaml:"sensitive_keys"`
+			HashKey          string   `yaml:"hash_key"`
 		}{
-			Level:  getEnvOrDefault("LOG_LEVEL", "info"),
-			Format: getEnvOrDefault("LOG_FORMAT", "json"),
+			Level:            getEnvOrDefault("LOG_LEVEL", "info"),
+			Format:           getEnvOrDefault("LOG_FORMAT", "json"),
+			DisableRedaction: getEnvOrDefault("LOG_DISABLE_REDACTION", "false") == "true",
+			SensitiveKeys:    splitList(os.Getenv("LOG_SENSITIVE_KEYS")),
+			HashKey:          os.Getenv("LOG_HASH_KEY"),
 		},
 	}
 }
@@ -221,7 +424,8 @@ func enableCORS(next http.Handler) http.Handler {
 		}
 
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 		w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
 
@@ -241,3 +445,50 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// splitList parses a comma-separated environment value, dropping blanks.
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// newApprovalSigner builds the signer for reviewer approval links. The
+// current key defaults to the JWT secret; previous keys ("id:secret") keep
+// links signed before a rotation valid until they expire.
+func newApprovalSigner(config *Config) (*approval.Signer, error) {
+	previousEntries := config.Approvals.PreviousKeys
+	if len(previousEntries) == 0 {
+		previousEntries = splitList(os.Getenv("APPROVAL_PREVIOUS_KEYS"))
+	}
+	previous, err := approval.ParseKeys(previousEntries)
+	if err != nil {
+		return nil, err
+	}
+
+	var ttl time.Duration
+	if raw := firstNonEmpty(config.Approvals.TokenTTL, os.Getenv("APPROVAL_TOKEN_TTL")); raw != "" {
+		if ttl, err = time.ParseDuration(raw); err != nil {
+			return nil, fmt.Errorf("invalid approval token ttl: %w", err)
+		}
+	}
+
+	current := approval.Key{
+		ID:     firstNonEmpty(config.Approvals.KeyID, os.Getenv("APPROVAL_KEY_ID")),
+		Secret: firstNonEmpty(config.Approvals.Secret, os.Getenv("APPROVAL_SECRET"), config.JWT.Secret),
+	}
+	return approval.NewSigner(current, previous, firstNonEmpty(config.Approvals.TokenIssuer, os.Getenv("APPROVAL_TOKEN_ISSUER")), ttl)
+}
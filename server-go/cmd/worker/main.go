@@ -2,14 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"email-tracking-server/internal/activities"
+	"email-tracking-server/internal/approval"
+	"email-tracking-server/internal/blobstore"
 	"email-tracking-server/internal/client"
+	"email-tracking-server/internal/consent"
+	"email-tracking-server/internal/emailhtml"
+	"email-tracking-server/internal/metrics"
+	"email-tracking-server/internal/serverapi"
 	"email-tracking-server/internal/workflows"
 	"email-tracking-server/pkg/logger"
 
@@ -28,17 +37,45 @@ type Config struct {
 		FromEmail     string `yaml:"from_email"`
 		RetryAttempts int    `yaml:"retry_attempts"`
 		RetryInterval string `yaml:"retry_interval"`
+		AssetBaseURL  string `yaml:"asset_base_url"`
 	} `yaml:"email"`
     JWT struct {
         Secret string `yaml:"secret"`
     } `yaml:"jwt"`
     Approvals struct {
-        ApproveBaseURL string `yaml:"approve_base_url"`
+        ApproveBaseURL string   `yaml:"approve_base_url"`
+        TokenIssuer    string   `yaml:"token_issuer"`
+        TokenTTL       string   `yaml:"token_ttl"`
+        KeyID          string   `yaml:"key_id"`
+        Secret         string   `yaml:"secret"`
+        PreviousKeys   []string `yaml:"previous_keys"`
     } `yaml:"approvals"`
 	Logging struct {
-		Level  string `yaml:"level"`
-		Format string `yaml:"format"`
+		Level            string   `yaml:"level"`
+		Format           string   `yaml:"format"`
+		DisableRedaction bool     `yaml:"disable_redaction"`
+		SensitiveKeys    []string `yaml:"sensitive_keys"`
+		HashKey          string   `yaml:"hash_key"`
 	} `yaml:"logging"`
+	Sanitizer   emailhtml.PolicyConfig `yaml:"sanitizer"`
+	Attachments struct {
+		Dir string `yaml:"dir"`
+	} `yaml:"attachments"`
+	Tracking struct {
+		Enabled bool   `yaml:"enabled"`
+		BaseURL string `yaml:"base_url"`
+	} `yaml:"tracking"`
+	Internal struct {
+		ServerURL string `yaml:"server_url"`
+		Token     string `yaml:"token"`
+	} `yaml:"internal"`
+	Database struct {
+		URL string `yaml:"url"`
+	} `yaml:"database"`
+	Metrics struct {
+		Enabled bool   `yaml:"enabled"`
+		Port    string `yaml:"port"`
+	} `yaml:"metrics"`
 }
 
 func main() {
@@ -50,7 +87,9 @@ func main() {
 	}
 
 	// Initialize logger
-	log := logger.New(config.Logging.Level, config.Logging.Format)
+	log := logger.NewWithRedaction(config.Logging.Level, config.Logging.Format,
+		config.Logging.Format == "json" && !config.Logging.DisableRedaction, config.Logging.SensitiveKeys,
+		firstNonEmpty(config.Logging.HashKey, os.Getenv("LOG_HASH_KEY"), config.JWT.Secret))
 	log.Info("Starting Temporal worker service")
 
 	// Initialize Temporal client
@@ -67,27 +106,101 @@ func main() {
 	// Create worker
 	w := worker.New(temporalClient.GetClient(), config.Temporal.TaskQueue, worker.Options{})
 
+	blobs, err := blobstore.NewFileStore(firstNonEmpty(config.Attachments.Dir, os.Getenv("ATTACHMENT_STORE_DIR"), "data/attachments"))
+	if err != nil {
+		log.Error("Failed to initialize attachment store", "error", err)
+		os.Exit(1)
+	}
+
+	// Client for the HTTP server's internal API (event timeline,
+	// suppression checks)
+	serverClient := serverapi.NewClient(
+		firstNonEmpty(config.Internal.ServerURL, os.Getenv("INTERNAL_SERVER_URL"), config.Approvals.ApproveBaseURL, os.Getenv("GO_EMAIL_SERVER_BASE_URL"), "https://tengine.zendwise.work"),
+		firstNonEmpty(config.Internal.Token, os.Getenv("INTERNAL_API_TOKEN")),
+	)
+	if !serverClient.Enabled() {
+		// Sending without the suppression check would mail blocked and
+		// bounced addresses, so refuse to start rather than skip it
+		log.Error("Internal API token not configured; the worker cannot check suppressions and will not start. Set INTERNAL_API_TOKEN (or internal.token) to the same value as the server, see config/.env.example")
+		os.Exit(1)
+	}
+
+	// Consent for marketing and newsletter sends is read from the main
+	// application's contacts table
+	var consents *consent.Store
+	if databaseURL := firstNonEmpty(config.Database.URL, os.Getenv("DATABASE_URL")); databaseURL != "" {
+		consents, err = consent.NewStore(context.Background(), databaseURL)
+		if err != nil {
+			log.Error("Failed to initialize consent store", "error", err)
+			os.Exit(1)
+		}
+		defer consents.Close()
+	} else {
+		log.Warn("DATABASE_URL not configured; marketing and newsletter emails will not be sent (consent_missing)")
+	}
+
+	// Tracking links point at the HTTP server; leaving the base empty turns
+	// tracking off
+	trackingBase := ""
+	if config.Tracking.Enabled {
+		trackingBase = firstNonEmpty(config.Tracking.BaseURL, config.Approvals.ApproveBaseURL, os.Getenv("GO_EMAIL_SERVER_BASE_URL"), "https://tengine.zendwise.work")
+	}
+
+	// Approval links are verified by the HTTP server, so both must share
+	// the approval keys and issuer
+	approvals, err := newApprovalSigner(config)
+	if errors.Is(err, approval.ErrNoKey) {
+		log.Warn("JWT secret not configured; approval emails cannot be sent")
+	} else if err != nil {
+		log.Error("Failed to configure approval tokens", "error", err)
+		os.Exit(1)
+	}
+
 	// Initialize email activity
     emailActivity := activities.NewEmailActivity(
         config.Email.ResendAPIKey,
         config.Email.FromEmail,
         config.JWT.Secret,
+        approvals,
         firstNonEmpty(config.Approvals.ApproveBaseURL, os.Getenv("GO_EMAIL_SERVER_BASE_URL"), "https://tengine.zendwise.work"),
+        firstNonEmpty(config.Email.AssetBaseURL, os.Getenv("EMAIL_ASSET_BASE_URL"), os.Getenv("MAIN_APP_URL")),
+        trackingBase,
+        emailhtml.NewPolicy(config.Sanitizer),
+        blobs,
+        serverClient,
+        consents,
         log,
     )
 
+	eventActivity := activities.NewEventActivity(serverClient, log)
+
 	// Register workflows and activities
 	w.RegisterWorkflow(workflows.EmailWorkflow)
 	w.RegisterWorkflow(workflows.ScheduledEmailWorkflow)
 	w.RegisterWorkflow(workflows.ReviewerApprovalEmailWorkflow)
+	w.RegisterWorkflow(workflows.SenderVerificationWorkflow)
     w.RegisterActivity(emailActivity.SendEmail)
     w.RegisterActivity(emailActivity.SendApprovalEmail)
     w.RegisterActivity(emailActivity.SendReviewerNotificationEmail)
+    w.RegisterActivity(emailActivity.SendSenderVerificationEmail)
+    w.RegisterActivity(eventActivity.RecordEmailEvent)
 
     log.Info("Temporal worker registered",
 		"task_queue", config.Temporal.TaskQueue,
-        "workflows", []string{"EmailWorkflow", "ScheduledEmailWorkflow", "ReviewerApprovalEmailWorkflow"},
-        "activities", []string{"SendEmail", "SendApprovalEmail", "SendReviewerNotificationEmail"})
+        "workflows", []string{"EmailWorkflow", "ScheduledEmailWorkflow", "ReviewerApprovalEmailWorkflow", "SenderVerificationWorkflow"},
+        "activities", []string{"SendEmail", "SendApprovalEmail", "SendReviewerNotificationEmail", "SendSenderVerificationEmail", "RecordEmailEvent"})
+
+	// Prometheus metrics on their own port, off the public API
+	var metricsServer *http.Server
+	if config.Metrics.Enabled || os.Getenv("ENABLE_METRICS") == "true" {
+		metricsServer = metrics.NewServer(":" + firstNonEmpty(config.Metrics.Port, os.Getenv("METRICS_PORT"), "9091"))
+		go func() {
+			log.Info("Starting metrics server", "addr", metricsServer.Addr)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error("Metrics server failed", "error", err)
+			}
+		}()
+	}
 
 	// Set up signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -118,6 +231,9 @@ func main() {
 		defer shutdownCancel()
 
 		w.Stop()
+		if metricsServer != nil {
+			metricsServer.Shutdown(shutdownCtx)
+		}
 
 		select {
 		case <-shutdownCtx.Done():
@@ -176,11 +292,13 @@ func loadConfigFromEnv() *Config {
 			FromEmail     string `yaml:"from_email"`
 			RetryAttempts int    `yaml:"retry_attempts"`
 			RetryInterval string `yaml:"retry_interval"`
+			AssetBaseURL  string `yaml:"asset_base_url"`
 		}{
 			ResendAPIKey:  getEnvOrDefault("RESEND_API_KEY", "re_f27r7h2s_BYXi6aNpimSCfCLwMeec686Q"),
 			FromEmail:     getEnvOrDefault("FROM_EMAIL", "noreply@zendwise.work"),
 			RetryAttempts: 5,
 			RetryInterval: "1m",
+			AssetBaseURL:  getEnvOrDefault("EMAIL_ASSET_BASE_URL", getEnvOrDefault("MAIN_APP_URL", "")),
 		},
         JWT: struct {
             Secret string `yaml:"secret"`
@@ -188,16 +306,41 @@ func loadConfigFromEnv() *Config {
             Secret: getEnvOrDefault("JWT_SECRET", ""),
         },
         Approvals: struct {
-            ApproveBaseURL string `yaml:"approve_base_url"`
+            ApproveBaseURL string   `yaml:"approve_base_url"`
+            TokenIssuer    string   `yaml:"token_issuer"`
+            TokenTTL       string   `yaml:"token_ttl"`
+            KeyID          string   `yaml:"key_id"`
+            Secret         string   `yaml:"secret"`
+            PreviousKeys   []string `yaml:"previous_keys"`
         }{
             ApproveBaseURL: getEnvOrDefault("GO_EMAIL_SERVER_BASE_URL", "https://tengine.zendwise.work"),
         },
 		Logging: struct {
-			Level  string `yaml:"level"`
-			Format string `yaml:"format"`
+			Level            string   `yaml:"level"`
+			Format           string   `yaml:"format"`
+			DisableRedaction bool     `yaml:"disable_redaction"`
+			SensitiveKeys    []string `yaml:"sensitive_keys"`
+			HashKey          string   `yaml:"hash_key"`
 		}{
-			Level:  getEnvOrDefault("LOG_LEVEL", "info"),
-			Format: getEnvOrDefault("LOG_FORMAT", "json"),
+			Level:            getEnvOrDefault("LOG_LEVEL", "info"),
+			Format:           getEnvOrDefault("LOG_FORMAT", "json"),
+			DisableRedaction: getEnvOrDefault("LOG_DISABLE_REDACTION", "false") == "true",
+			SensitiveKeys:    splitList(os.Getenv("LOG_SENSITIVE_KEYS")),
+			HashKey:          os.Getenv("LOG_HASH_KEY"),
+		},
+		Tracking: struct {
+			Enabled bool   `yaml:"enabled"`
+			BaseURL string `yaml:"base_url"`
+		}{
+			Enabled: getEnvOrDefault("TRACKING_ENABLED", "true") == "true",
+			BaseURL: os.Getenv("TRACKING_BASE_URL"),
+		},
+		Internal: struct {
+			ServerURL string `yaml:"server_url"`
+			Token     string `yaml:"token"`
+		}{
+			ServerURL: os.Getenv("INTERNAL_SERVER_URL"),
+			Token:     os.Getenv("INTERNAL_API_TOKEN"),
 		},
 	}
 }
@@ -217,3 +360,41 @@ func firstNonEmpty(values ...string) string {
     }
     return ""
 }
+
+// splitList parses a comma-separated environment value, dropping blanks.
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// newApprovalSigner builds the signer for reviewer approval links. The
+// current key defaults to the JWT secret; previous keys ("id:secret") keep
+// links signed before a rotation valid until they expire.
+func newApprovalSigner(config *Config) (*approval.Signer, error) {
+	previousEntries := config.Approvals.PreviousKeys
+	if len(previousEntries) == 0 {
+		previousEntries = splitList(os.Getenv("APPROVAL_PREVIOUS_KEYS"))
+	}
+	previous, err := approval.ParseKeys(previousEntries)
+	if err != nil {
+		return nil, err
+	}
+
+	var ttl time.Duration
+	if raw := firstNonEmpty(config.Approvals.TokenTTL, os.Getenv("APPROVAL_TOKEN_TTL")); raw != "" {
+		if ttl, err = time.ParseDuration(raw); err != nil {
+			return nil, fmt.Errorf("invalid approval token ttl: %w", err)
+		}
+	}
+
+	current := approval.Key{
+		ID:     firstNonEmpty(config.Approvals.KeyID, os.Getenv("APPROVAL_KEY_ID")),
+		Secret: firstNonEmpty(config.Approvals.Secret, os.Getenv("APPROVAL_SECRET"), config.JWT.Secret),
+	}
+	return approval.NewSigner(current, previous, firstNonEmpty(config.Approvals.TokenIssuer, os.Getenv("APPROVAL_TOKEN_ISSUER")), ttl)
+}
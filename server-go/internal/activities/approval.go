@@ -0,0 +1,30 @@
+package activities
+
+import (
+	"fmt"
+	"net/url"
+
+	"email-tracking-server/internal/approval"
+)
+
+// approvalLinks signs the approve and reject links for the reviewer of an
+// email. Both are bound to the reviewer and carry their own jti, so each link
+// can be used once. They expire when the workflow stops waiting for approval.
+func (ea *EmailActivity) approvalLinks(emailData EmailData, reviewerEmail string) (string, string, error) {
+	reviewerID, _ := emailData.Metadata["reviewerId"].(string)
+
+	base := ea.approveBase
+	if base == "" {
+		base = "https://tengine.zendwise.work"
+	}
+
+	links := make([]string, 0, 2)
+	for _, action := range []string{approval.ActionApprove, approval.ActionReject} {
+		token, err := ea.approvals.IssueWithTTL(emailData.EmailID, reviewerEmail, reviewerID, action, emailData.ApprovalTimeout)
+		if err != nil {
+			return "", "", err
+		}
+		links = append(links, fmt.Sprintf("%s/approve-email?token=%s", base, url.QueryEscape(token)))
+	}
+	return links[0], links[1], nil
+}
@@ -0,0 +1,64 @@
+package activities
+
+import (
+	"fmt"
+
+	"email-tracking-server/internal/blobstore"
+
+	"github.com/resend/resend-go/v2"
+)
+
+// Attachment limits enforced when a tracking entry is created. The total
+// stays under the provider's 40MB message limit.
+const (
+	MaxAttachments         = 10
+	MaxAttachmentSize      = 10 << 20
+	MaxTotalAttachmentSize = 35 << 20
+)
+
+// AllowedAttachmentTypes lists the MIME types accepted for attachments.
+var AllowedAttachmentTypes = map[string]bool{
+	"application/pdf": true,
+	"text/csv":        true,
+	"text/plain":      true,
+	"text/calendar":   true,
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":       true,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": true,
+}
+
+// Attachment describes a file sent with an email. The content lives in the
+// blob store under BlobKey so it never travels through Temporal payloads.
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	BlobKey     string `json:"blobKey"`
+}
+
+// loadAttachments reads attachment content from the blob store for the
+// provider request.
+func loadAttachments(store blobstore.Store, attachments []Attachment) ([]*resend.Attachment, error) {
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+	if store == nil {
+		return nil, fmt.Errorf("attachments present but no blob store is configured")
+	}
+
+	loaded := make([]*resend.Attachment, 0, len(attachments))
+	for _, a := range attachments {
+		data, err := store.Get(a.BlobKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load attachment %q: %w", a.Filename, err)
+		}
+		loaded = append(loaded, &resend.Attachment{
+			Content:     data,
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+		})
+	}
+	return loaded, nil
+}
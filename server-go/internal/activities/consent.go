@@ -0,0 +1,28 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+
+	"email-tracking-server/internal/consent"
+)
+
+// hasConsent reports whether the recipient may be sent this email. Marketing
+// and newsletter emails need consent recorded on the tenant's contact; every
+// other template type is allowed through. Test sends are exempt: they only
+// go to the requesting user's own address. Without a contacts database no
+// consent can be found, so emails that need it are not sent.
+func (ea *EmailActivity) hasConsent(ctx context.Context, emailData EmailData, recipient string) (bool, error) {
+	templateType, _ := emailData.Metadata["templateType"].(string)
+	if emailData.Test || !consent.Required(templateType) {
+		return true, nil
+	}
+	if ea.consents == nil {
+		return false, nil
+	}
+	record, found, err := ea.consents.Lookup(ctx, emailData.TenantID, recipient)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify consent: %w", err)
+	}
+	return found && record.Valid(), nil
+}
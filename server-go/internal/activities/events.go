@@ -0,0 +1,45 @@
+package activities
+
+import (
+	"context"
+
+	"email-tracking-server/internal/metrics"
+	"email-tracking-server/internal/serverapi"
+	"email-tracking-server/pkg/logger"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// EmailEvent is a timeline event reported by a workflow.
+type EmailEvent struct {
+	EntryID string                 `json:"entryId"`
+	Type    string                 `json:"type"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// EventActivity reports workflow progress to the server's event timeline.
+type EventActivity struct {
+	server *serverapi.Client
+	logger *logger.Logger
+}
+
+func NewEventActivity(server *serverapi.Client, log *logger.Logger) *EventActivity {
+	return &EventActivity{
+		server: server,
+		logger: log,
+	}
+}
+
+// RecordEmailEvent posts the event to the server. It is a no-op when the
+// internal API is not configured.
+func (ea *EventActivity) RecordEmailEvent(ctx context.Context, event EmailEvent) error {
+	metrics.ActivityAttempt("RecordEmailEvent", activity.GetInfo(ctx).Attempt)
+	if !ea.server.Enabled() {
+		return nil
+	}
+	if err := ea.server.RecordEvent(ctx, event.EntryID, event.Type, event.Details); err != nil {
+		ea.logger.WithContext(ctx).Warn("Failed to record email event", "error", err, "entry_id", event.EntryID, "type", event.Type)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package activities
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLToText converts an HTML email body into a readable text/plain
+// alternative. Block elements become separate lines, list items are bulleted,
+// table rows are flattened to "cell | cell" lines and links are replaced by
+// numbered footnotes listed at the end.
+func HTMLToText(doc string) string {
+	root, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		return strings.TrimSpace(doc)
+	}
+
+	c := &textConverter{}
+	c.walk(root)
+	c.flushLine()
+
+	out := strings.Join(collapseBlankLines(c.lines), "\n")
+	if len(c.links) > 0 {
+		var footnotes strings.Builder
+		for i, link := range c.links {
+			fmt.Fprintf(&footnotes, "\n[%d] %s", i+1, link)
+		}
+		out += "\n\n" + strings.TrimPrefix(footnotes.String(), "\n")
+	}
+	return strings.TrimSpace(out)
+}
+
+type textConverter struct {
+	lines []string
+	line  strings.Builder
+	links []string
+	// linkIndex de-duplicates footnotes for repeated URLs
+	linkIndex map[string]int
+}
+
+func (c *textConverter) write(s string) {
+	c.line.WriteString(s)
+}
+
+func (c *textConverter) flushLine() {
+	if line := strings.Join(strings.Fields(c.line.String()), " "); line != "" {
+		c.lines = append(c.lines, line)
+	}
+	c.line.Reset()
+}
+
+func (c *textConverter) blankLine() {
+	c.flushLine()
+	if n := len(c.lines); n > 0 && c.lines[n-1] != "" {
+		c.lines = append(c.lines, "")
+	}
+}
+
+func (c *textConverter) footnote(href string) int {
+	if c.linkIndex == nil {
+		c.linkIndex = make(map[string]int)
+	}
+	if n, ok := c.linkIndex[href]; ok {
+		return n
+	}
+	c.links = append(c.links, href)
+	c.linkIndex[href] = len(c.links)
+	return len(c.links)
+}
+
+func (c *textConverter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		c.write(n.Data)
+		return
+	case html.ElementNode:
+		// handled below
+	default:
+		c.walkChildren(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Head, atom.Style, atom.Script, atom.Title, atom.Noscript:
+		return
+	case atom.Br:
+		c.flushLine()
+	case atom.Hr:
+		c.flushLine()
+		c.lines = append(c.lines, "----------")
+	case atom.Img:
+		if alt := attr(n, "alt"); alt != "" {
+			c.write(" " + alt + " ")
+		}
+	case atom.A:
+		c.walkChildren(n)
+		href := attr(n, "href")
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			return
+		}
+		fmt.Fprintf(&c.line, " [%d]", c.footnote(href))
+	case atom.P, atom.Div, atom.Blockquote, atom.Section, atom.Article, atom.Header, atom.Footer, atom.Center:
+		c.blankLine()
+		c.walkChildren(n)
+		c.blankLine()
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		c.blankLine()
+		c.walkChildren(n)
+		heading := strings.ToUpper(c.line.String())
+		c.line.Reset()
+		c.write(heading)
+		c.blankLine()
+	case atom.Ul, atom.Ol:
+		c.flushLine()
+		index := 0
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode || child.DataAtom != atom.Li {
+				c.walk(child)
+				continue
+			}
+			index++
+			if n.DataAtom == atom.Ol {
+				fmt.Fprintf(&c.line, "%d. ", index)
+			} else {
+				c.write("- ")
+			}
+			c.walkChildren(child)
+			c.flushLine()
+		}
+		c.blankLine()
+	case atom.Table:
+		c.blankLine()
+		c.walkTable(n)
+		c.blankLine()
+	default:
+		c.walkChildren(n)
+	}
+}
+
+func (c *textConverter) walkChildren(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.walk(child)
+	}
+}
+
+// walkTable flattens each row into a single line. Layout tables with a single
+// cell per row therefore read like ordinary paragraphs.
+func (c *textConverter) walkTable(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+		switch child.DataAtom {
+		case atom.Thead, atom.Tbody, atom.Tfoot:
+			c.walkTable(child)
+		case atom.Tr:
+			c.walkRow(child)
+		}
+	}
+}
+
+func (c *textConverter) walkRow(tr *html.Node) {
+	c.flushLine()
+	var cells []string
+	for cell := tr.FirstChild; cell != nil; cell = cell.NextSibling {
+		if cell.Type != html.ElementNode || (cell.DataAtom != atom.Td && cell.DataAtom != atom.Th) {
+			continue
+		}
+		// Render the cell on its own so nested blocks don't split the row
+		sub := &textConverter{links: c.links, linkIndex: c.linkIndex}
+		sub.walkChildren(cell)
+		sub.flushLine()
+		c.links, c.linkIndex = sub.links, sub.linkIndex
+		if text := strings.Join(collapseBlankLines(sub.lines), " "); strings.TrimSpace(text) != "" {
+			cells = append(cells, strings.TrimSpace(text))
+		}
+	}
+	if len(cells) > 0 {
+		c.lines = append(c.lines, strings.Join(cells, " | "))
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// collapseBlankLines removes leading, trailing and repeated blank lines.
+func collapseBlankLines(lines []string) []string {
+	var out []string
+	for _, line := range lines {
+		if line == "" && (len(out) == 0 || out[len(out)-1] == "") {
+			continue
+		}
+		out = append(out, line)
+	}
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	return out
+}
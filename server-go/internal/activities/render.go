@@ -0,0 +1,87 @@
+package activities
+
+import (
+	"fmt"
+
+	"email-tracking-server/internal/emailhtml"
+	"email-tracking-server/internal/templates"
+)
+
+// RenderedEmail is the final subject and bodies for a single recipient.
+type RenderedEmail struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// RenderEmail produces the message SendEmail delivers to recipient. The
+// preview endpoint uses it too, so previews match real sends. The rendered
+// campaign body is sanitized with policy before it is placed in the layout,
+// and the whole rendered document again afterwards, since a tenant template
+// version supplies its own markup.
+func RenderEmail(emailData EmailData, recipient string, policy *emailhtml.Policy) (*RenderedEmail, error) {
+	subjectTemplate := subjectFor(emailData)
+	if subjectTemplate == "" {
+		return nil, fmt.Errorf("subject not found or invalid in metadata")
+	}
+
+	// A pinned template can carry the whole body, so content is only required
+	// when falling back to the built-in layouts
+	content, _ := emailData.Metadata["content"].(string)
+	if content == "" && emailData.Template == nil {
+		return nil, fmt.Errorf("content not found or invalid in metadata")
+	}
+
+	templateType, _ := emailData.Metadata["templateType"].(string)
+	data := templates.MergeDataFromMetadata(recipient, emailData.Metadata)
+
+	subject, err := templates.RenderSubject(subjectTemplate, data)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := templates.Render(content, data)
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		body = policy.Sanitize(body)
+	}
+
+	var rendered string
+	if emailData.Template != nil {
+		rendered, err = templates.RenderVersion(*emailData.Template, body, data)
+	} else {
+		rendered, err = templates.RenderLayout(templateType, body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		rendered = policy.SanitizeDocument(rendered)
+	}
+
+	// Callers may supply their own text part; otherwise derive it from the HTML
+	text := HTMLToText(rendered)
+	if textContent, _ := emailData.Metadata["textContent"].(string); textContent != "" {
+		if text, err = templates.RenderText(textContent, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return &RenderedEmail{
+		Subject: subject,
+		HTML:    rendered,
+		Text:    text,
+	}, nil
+}
+
+// subjectFor returns the subject template: the caller's metadata subject, or
+// the pinned template version's.
+func subjectFor(emailData EmailData) string {
+	subject, _ := emailData.Metadata["subject"].(string)
+	if subject == "" && emailData.Template != nil {
+		subject = emailData.Template.Subject
+	}
+	return subject
+}
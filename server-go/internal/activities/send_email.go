@@ -3,13 +3,21 @@ package activities
 import (
 	"context"
 	"fmt"
-    "net/url"
+	"html"
+	"strings"
 	"time"
 
+	"email-tracking-server/internal/approval"
+	"email-tracking-server/internal/blobstore"
+	"email-tracking-server/internal/consent"
+	"email-tracking-server/internal/emailhtml"
+	"email-tracking-server/internal/metrics"
+	"email-tracking-server/internal/serverapi"
+	"email-tracking-server/internal/templates"
 	"email-tracking-server/pkg/logger"
-    "github.com/golang-jwt/jwt/v5"
 	"github.com/resend/resend-go/v2"
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 )
 
 type EmailActivity struct {
@@ -17,7 +25,14 @@ type EmailActivity struct {
 	fromEmail    string
 	logger       *logger.Logger
     jwtSecret    string
+    approvals    *approval.Signer
     approveBase  string
+	assetBase    string
+	trackingBase string
+	policy       *emailhtml.Policy
+	blobs        blobstore.Store
+	server       *serverapi.Client
+	consents     *consent.Store
 }
 
 type EmailData struct {
@@ -28,15 +43,35 @@ type EmailData struct {
 	Status      string                 `json:"status"`
 	Timestamp   time.Time              `json:"timestamp"`
 	Workflow    string                 `json:"temporalWorkflow,omitempty"`
+	// Test marks a single test copy that must be excluded from analytics
+	Test        bool                   `json:"test,omitempty"`
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	// Template is the tenant template version pinned on the tracking entry, if any
+	Template    *templates.Version     `json:"template,omitempty"`
+	Cc          []string               `json:"cc,omitempty"`
+	Bcc         []string               `json:"bcc,omitempty"`
+	ReplyTo     string                 `json:"replyTo,omitempty"`
+	Headers     map[string]string      `json:"headers,omitempty"`
+	Attachments []Attachment           `json:"attachments,omitempty"`
+	// From is the tenant's verified sender address with display name; empty
+	// means the system default
+	From        string                 `json:"from,omitempty"`
+	// ApprovalTimeout is how long an approval workflow waits for the reviewer,
+	// and how long the approval links stay valid; zero means the default
+	ApprovalTimeout time.Duration `json:"approvalTimeout,omitempty"`
 }
 
 type SendEmailRequest struct {
-    To       string `json:"to"`
-    Subject  string `json:"subject"`
-    Content  string `json:"content"`
-    Priority string `json:"priority"`
-    Template string `json:"template"`
+    To          string            `json:"to"`
+    Cc          []string          `json:"cc,omitempty"`
+    Bcc         []string          `json:"bcc,omitempty"`
+    ReplyTo     string            `json:"replyTo,omitempty"`
+    Subject     string            `json:"subject"`
+    Content     string            `json:"content"`
+    Priority    string            `json:"priority"`
+    Template    string            `json:"template"`
+    Headers     map[string]string `json:"headers,omitempty"`
+    Attachments []Attachment      `json:"attachments,omitempty"`
 }
 
 type SendEmailResult struct {
@@ -47,7 +82,7 @@ type SendEmailResult struct {
     Error    string    `json:"error,omitempty"`
 }
 
-func NewEmailActivity(apiKey string, fromEmail string, jwtSecret string, approveBaseURL string, log *logger.Logger) *EmailActivity {
+func NewEmailActivity(apiKey string, fromEmail string, jwtSecret string, approvals *approval.Signer, approveBaseURL string, assetBaseURL string, trackingBaseURL string, policy *emailhtml.Policy, blobs blobstore.Store, server *serverapi.Client, consents *consent.Store, log *logger.Logger) *EmailActivity {
 	resendClient := resend.NewClient(apiKey)
 	
 	return &EmailActivity{
@@ -55,11 +90,35 @@ func NewEmailActivity(apiKey string, fromEmail string, jwtSecret string, approve
         fromEmail:    fromEmail,
         logger:       log,
         jwtSecret:    jwtSecret,
+        approvals:    approvals,
         approveBase:  approveBaseURL,
+		assetBase:    assetBaseURL,
+		trackingBase: strings.TrimRight(trackingBaseURL, "/"),
+		policy:       policy,
+		blobs:        blobs,
+		server:       server,
+		consents:     consents,
 	}
 }
 
 func (ea *EmailActivity) SendEmail(ctx context.Context, emailData EmailData) (*SendEmailResult, error) {
+	metrics.ActivityAttempt("SendEmail", activity.GetInfo(ctx).Attempt)
+
+	result, err := ea.sendEmail(ctx, emailData)
+	// Test sends are kept out of the counts like they are out of analytics
+	templateType, _ := emailData.Metadata["templateType"].(string)
+	if emailData.Test {
+		return result, err
+	}
+	if err != nil || result.Status == "failed" {
+		metrics.EmailFailed(emailData.TenantID, templateType)
+	} else if result.Status == "sent" {
+		metrics.EmailSent(emailData.TenantID, templateType)
+	}
+	return result, err
+}
+
+func (ea *EmailActivity) sendEmail(ctx context.Context, emailData EmailData) (*SendEmailResult, error) {
 	logger := ea.logger.WithEmail(emailData.EmailID).WithContext(ctx)
 	logger.Info("Starting email send activity", "recipient", emailData.Metadata["recipient"])
 
@@ -76,10 +135,11 @@ func (ea *EmailActivity) SendEmail(ctx context.Context, emailData EmailData) (*S
 		}, err
 	}
 
-	subject, ok := emailData.Metadata["subject"].(string)
-	if !ok || subject == "" {
-		err := fmt.Errorf("subject not found or invalid in metadata")
-		logger.Error("Invalid subject", "error", err)
+	// A suppressed recipient is final for this send, so report it as a
+	// result rather than an error that Temporal would retry
+	cc, bcc, suppressed, err := ea.checkSuppressions(ctx, emailData, recipient)
+	if err != nil {
+		logger.Error("Suppression check failed", "error", err)
 		return &SendEmailResult{
 			EmailID: emailData.EmailID,
 			Status:  "failed",
@@ -87,11 +147,59 @@ func (ea *EmailActivity) SendEmail(ctx context.Context, emailData EmailData) (*S
 			Error:   err.Error(),
 		}, err
 	}
+	if suppressed != nil {
+		logger.Warn("Recipient is suppressed, not sending", "reason", suppressed.Reason, "global", suppressed.Global)
+		return &SendEmailResult{
+			EmailID: emailData.EmailID,
+			Status:  "suppressed",
+			SentAt:  time.Now(),
+			Error:   fmt.Sprintf("recipient is suppressed (%s)", suppressed.Reason),
+		}, nil
+	}
+	emailData.Cc, emailData.Bcc = cc, bcc
 
-	content, ok := emailData.Metadata["content"].(string)
-	if !ok || content == "" {
-		err := fmt.Errorf("content not found or invalid in metadata")
-		logger.Error("Invalid content", "error", err)
+	consented, err := ea.hasConsent(ctx, emailData, recipient)
+	if err != nil {
+		logger.Error("Consent check failed", "error", err)
+		return &SendEmailResult{
+			EmailID: emailData.EmailID,
+			Status:  "failed",
+			SentAt:  time.Now(),
+			Error:   err.Error(),
+		}, err
+	}
+	if !consented {
+		logger.Info("No recorded consent for recipient, not sending")
+		return &SendEmailResult{
+			EmailID: emailData.EmailID,
+			Status:  "consent_missing",
+			SentAt:  time.Now(),
+			Error:   "recipient has no recorded consent for this email type",
+		}, nil
+	}
+
+	category, optedOut, err := ea.optedOut(ctx, emailData, recipient)
+	if err != nil {
+		logger.Error("Preference check failed", "error", err)
+		return &SendEmailResult{
+			EmailID: emailData.EmailID,
+			Status:  "failed",
+			SentAt:  time.Now(),
+			Error:   err.Error(),
+		}, err
+	}
+	if optedOut {
+		logger.Info("Recipient opted out of category, not sending", "category", category)
+		return &SendEmailResult{
+			EmailID: emailData.EmailID,
+			Status:  "opted_out",
+			SentAt:  time.Now(),
+			Error:   fmt.Sprintf("recipient opted out of %s emails", category),
+		}, nil
+	}
+
+	if emailData, err = ea.addSubscriptionLinks(emailData, recipient); err != nil {
+		logger.Error("Failed to add subscription links", "error", err)
 		return &SendEmailResult{
 			EmailID: emailData.EmailID,
 			Status:  "failed",
@@ -106,23 +214,69 @@ func (ea *EmailActivity) SendEmail(ctx context.Context, emailData EmailData) (*S
 
 	logger.Info("Sending email via Resend", 
 		"to", recipient, 
-		"subject", subject,
+		"subject", subjectFor(emailData),
 		"template", templateType,
 		"priority", priority)
 
+	rendered, err := RenderEmail(emailData, recipient, ea.policy)
+	if err != nil {
+		// Rendering is deterministic, so retrying would fail the same way
+		logger.Error("Failed to render email content", "error", err)
+		return &SendEmailResult{
+			EmailID: emailData.EmailID,
+			Status:  "failed",
+			SentAt:  time.Now(),
+			Error:   err.Error(),
+		}, temporal.NewNonRetryableApplicationError(err.Error(), "RenderError", err)
+	}
+
+	attachments, err := loadAttachments(ea.blobs, emailData.Attachments)
+	if err != nil {
+		logger.Error("Failed to load attachments", "error", err)
+		return &SendEmailResult{
+			EmailID: emailData.EmailID,
+			Status:  "failed",
+			SentAt:  time.Now(),
+			Error:   err.Error(),
+		}, err
+	}
+
+	body := ea.prepareHTML(rendered.HTML)
+	if ea.trackingEnabled(emailData, "trackClicks") {
+		if body, err = ea.rewriteLinks(body, emailData, recipient); err != nil {
+			logger.Warn("Sending without click tracking", "error", err)
+		}
+	}
+	if ea.trackingEnabled(emailData, "trackOpens") {
+		if body, err = ea.addOpenPixel(body, emailData, recipient); err != nil {
+			logger.Warn("Sending without open tracking", "error", err)
+		}
+	}
+
+	from := ea.fromEmail
+	if emailData.From != "" {
+		from = emailData.From
+	}
+
 	// Create email request for Resend
 	params := &resend.SendEmailRequest{
-		From:    ea.fromEmail,
-		To:      []string{recipient},
-		Subject: subject,
-		Html:    ea.formatEmailContent(content, templateType),
+		From:        from,
+		To:          []string{recipient},
+		Cc:          emailData.Cc,
+		Bcc:         emailData.Bcc,
+		ReplyTo:     emailData.ReplyTo,
+		Subject:     rendered.Subject,
+		Html:        body,
+		Text:        rendered.Text,
+		Headers:     emailData.Headers,
+		Attachments: attachments,
 	}
 
 	// Add activity heartbeat for long-running operations
 	activity.RecordHeartbeat(ctx, "Sending email via Resend")
 
 	// Send email via Resend
-	sent, err := ea.resendClient.Emails.Send(params)
+	sent, err := ea.send("email", params)
 	if err != nil {
 		logger.Error("Failed to send email via Resend", "error", err)
 		return &SendEmailResult{
@@ -152,13 +306,14 @@ func (ea *EmailActivity) SendEmail(ctx context.Context, emailData EmailData) (*S
 // If reviewerEmail is not provided, the activity logs a warning and returns a non-fatal result,
 // allowing the workflow to continue waiting for approval.
 func (ea *EmailActivity) SendApprovalEmail(ctx context.Context, emailData EmailData) (*SendEmailResult, error) {
+    metrics.ActivityAttempt("SendApprovalEmail", activity.GetInfo(ctx).Attempt)
     logger := ea.logger.WithEmail(emailData.EmailID).WithContext(ctx)
     logger.Info("Starting approval email activity")
 
     // Validate configuration
-    if ea.jwtSecret == "" {
-        err := fmt.Errorf("jwt secret not configured in worker")
-        logger.Error("Missing JWT secret", "error", err)
+    if ea.approvals == nil {
+        err := fmt.Errorf("approval signing not configured in worker")
+        logger.Error("Missing approval signer", "error", err)
         return &SendEmailResult{EmailID: emailData.EmailID, Status: "approval_email_failed", SentAt: time.Now(), Error: err.Error()}, err
     }
 
@@ -181,36 +336,12 @@ func (ea *EmailActivity) SendApprovalEmail(ctx context.Context, emailData EmailD
         }, nil
     }
 
-    // Compose token with emailId and expected workflowId convention
-    workflowID := fmt.Sprintf("reviewer-email-workflow-%s", emailData.EmailID)
-
-    type ApprovalClaims struct {
-        EmailID    string `json:"emailId"`
-        WorkflowID string `json:"workflowId"`
-        jwt.RegisteredClaims
-    }
-
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, ApprovalClaims{
-        EmailID:    emailData.EmailID,
-        WorkflowID: workflowID,
-        RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
-            IssuedAt:  jwt.NewNumericDate(time.Now()),
-        },
-    })
-
-    signed, err := token.SignedString([]byte(ea.jwtSecret))
+    approveURL, rejectURL, err := ea.approvalLinks(emailData, reviewerEmail)
     if err != nil {
         logger.Error("Failed to sign approval token", "error", err)
         return &SendEmailResult{EmailID: emailData.EmailID, Status: "approval_email_failed", SentAt: time.Now(), Error: err.Error()}, err
     }
 
-    base := ea.approveBase
-    if base == "" {
-        base = "https://tengine.zendwise.work"
-    }
-    approveURL := fmt.Sprintf("%s/approve-email?token=%s", base, url.QueryEscape(signed))
-
     // Subject and content
     subject, _ := emailData.Metadata["subject"].(string)
     if subject == "" {
@@ -220,7 +351,8 @@ func (ea *EmailActivity) SendApprovalEmail(ctx context.Context, emailData EmailD
     html := fmt.Sprintf(`<p>You have a pending email campaign awaiting your approval.</p>
 <p><a href="%s" style="display:inline-block;padding:10px 16px;background:#4f46e5;color:white;border-radius:6px;text-decoration:none;">Approve Email</a></p>
 <p>If the button doesn't work, click or copy this link:</p>
-<p>%s</p>`, approveURL, approveURL)
+<p>%s</p>
+<p>Not ready to send? <a href="%s">Reject this email</a>.</p>`, approveURL, approveURL, rejectURL)
 
     // Heartbeat and send
     activity.RecordHeartbeat(ctx, "Sending approval email via Resend")
@@ -228,9 +360,9 @@ func (ea *EmailActivity) SendApprovalEmail(ctx context.Context, emailData EmailD
         From:    ea.fromEmail,
         To:      []string{reviewerEmail},
         Subject: approvalSubject,
-        Html:    html,
+        Html:    ea.prepareHTML(html),
     }
-    sent, sendErr := ea.resendClient.Emails.Send(params)
+    sent, sendErr := ea.send("approval_email", params)
     if sendErr != nil {
         logger.Error("Failed to send approval email via Resend", "error", sendErr)
         return &SendEmailResult{EmailID: emailData.EmailID, Status: "approval_email_failed", SentAt: time.Now(), Error: sendErr.Error()}, sendErr
@@ -243,13 +375,14 @@ func (ea *EmailActivity) SendApprovalEmail(ctx context.Context, emailData EmailD
 // SendReviewerNotificationEmail sends a notification email to a reviewer when an email requires approval.
 // This activity is triggered as part of the email workflow when reviewer approval is required.
 func (ea *EmailActivity) SendReviewerNotificationEmail(ctx context.Context, emailData EmailData) (*SendEmailResult, error) {
+    metrics.ActivityAttempt("SendReviewerNotificationEmail", activity.GetInfo(ctx).Attempt)
     logger := ea.logger.WithEmail(emailData.EmailID).WithContext(ctx)
     logger.Info("Starting reviewer notification email activity")
 
     // Validate configuration
-    if ea.jwtSecret == "" {
-        err := fmt.Errorf("jwt secret not configured in worker")
-        logger.Error("Missing JWT secret", "error", err)
+    if ea.approvals == nil {
+        err := fmt.Errorf("approval signing not configured in worker")
+        logger.Error("Missing approval signer", "error", err)
         return &SendEmailResult{EmailID: emailData.EmailID, Status: "reviewer_notification_failed", SentAt: time.Now(), Error: err.Error()}, err
     }
 
@@ -272,43 +405,22 @@ func (ea *EmailActivity) SendReviewerNotificationEmail(ctx context.Context, emai
         }, nil
     }
 
-    // Generate JWT token for approval link
-    workflowID := fmt.Sprintf("reviewer-email-workflow-%s", emailData.EmailID)
-
-    type ApprovalClaims struct {
-        EmailID    string `json:"emailId"`
-        WorkflowID string `json:"workflowId"`
-        jwt.RegisteredClaims
-    }
-
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, ApprovalClaims{
-        EmailID:    emailData.EmailID,
-        WorkflowID: workflowID,
-        RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
-            IssuedAt:  jwt.NewNumericDate(time.Now()),
-        },
-    })
-
-    signed, err := token.SignedString([]byte(ea.jwtSecret))
+    approveURL, rejectURL, err := ea.approvalLinks(emailData, reviewerEmail)
     if err != nil {
         logger.Error("Failed to sign approval token", "error", err)
         return &SendEmailResult{EmailID: emailData.EmailID, Status: "reviewer_notification_failed", SentAt: time.Now(), Error: err.Error()}, err
     }
 
-    base := ea.approveBase
-    if base == "" {
-        base = "https://tengine.zendwise.work"
-    }
-    approveURL := fmt.Sprintf("%s/approve-email?token=%s", base, url.QueryEscape(signed))
-
     // Extract campaign details for email content
     subject, _ := emailData.Metadata["subject"].(string)
     if subject == "" {
         subject = "Email campaign"
     }
     
+    // Campaign fields are user-supplied, so escape them and sanitize the
+    // preview before they reach the reviewer's inbox
     campaignContent, _ := emailData.Metadata["content"].(string)
+    campaignContent = ea.policy.Sanitize(campaignContent)
     campaignTo, _ := emailData.Metadata["to"].(string)
     
     approvalSubject := fmt.Sprintf("Review Required: %s", subject)
@@ -361,6 +473,7 @@ func (ea *EmailActivity) SendReviewerNotificationEmail(ctx context.Context, emai
                     
                     <div style="text-align: center; margin: 24px 0;">
                         <a href="%s" class="button">✅ Approve Campaign</a>
+                        <a href="%s" class="button" style="background: #6b7280;">Reject</a>
                     </div>
                     
                     <p><strong>What happens when you approve:</strong></p>
@@ -375,7 +488,7 @@ func (ea *EmailActivity) SendReviewerNotificationEmail(ctx context.Context, emai
                         %s
                     </p>
                     
-                    <p><em>This approval link will expire in 7 days.</em></p>
+                    <p><em>These links expire on %s.</em></p>
                 </div>
                 <div class="footer">
                     <p>This approval request was sent to %s</p>
@@ -384,7 +497,7 @@ func (ea *EmailActivity) SendReviewerNotificationEmail(ctx context.Context, emai
             </div>
         </body>
         </html>
-    `, subject, campaignTo, emailData.EmailID, campaignContent, approveURL, approveURL, reviewerEmail)
+    `, html.EscapeString(subject), html.EscapeString(campaignTo), html.EscapeString(emailData.EmailID), campaignContent, approveURL, rejectURL, html.EscapeString(approveURL), time.Now().Add(ea.approvals.TTL()).UTC().Format("January 2, 2006 15:04 MST"), html.EscapeString(reviewerEmail))
 
     // Send the reviewer notification email
     activity.RecordHeartbeat(ctx, "Sending reviewer notification email via Resend")
@@ -392,10 +505,10 @@ func (ea *EmailActivity) SendReviewerNotificationEmail(ctx context.Context, emai
         From:    ea.fromEmail,
         To:      []string{reviewerEmail},
         Subject: approvalSubject,
-        Html:    html,
+        Html:    ea.prepareHTML(html),
     }
     
-    sent, sendErr := ea.resendClient.Emails.Send(params)
+    sent, sendErr := ea.send("reviewer_notification", params)
     if sendErr != nil {
         logger.Error("Failed to send reviewer notification email via Resend", "error", sendErr)
         return &SendEmailResult{EmailID: emailData.EmailID, Status: "reviewer_notification_failed", SentAt: time.Now(), Error: sendErr.Error()}, sendErr
@@ -414,73 +527,22 @@ func (ea *EmailActivity) SendReviewerNotificationEmail(ctx context.Context, emai
     }, nil
 }
 
-func (ea *EmailActivity) formatEmailContent(content, templateType string) string {
-	// Basic HTML formatting based on template type
-	switch templateType {
-	case "marketing":
-		return fmt.Sprintf(`
-			<html>
-			<body style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
-				<div style="background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%); padding: 20px; text-align: center;">
-					<h1 style="color: white; margin: 0;">📧 Marketing Email</h1>
-				</div>
-				<div style="padding: 30px; background: #f9f9f9;">
-					<div style="background: white; padding: 20px; border-radius: 8px; box-shadow: 0 2px 4px rgba(0,0,0,0.1);">
-						%s
-					</div>
-				</div>
-				<div style="background: #333; color: white; padding: 15px; text-align: center; font-size: 12px;">
-					Sent via Authentik Email Campaign System
-				</div>
-			</body>
-			</html>
-		`, content)
-	case "transactional":
-		return fmt.Sprintf(`
-			<html>
-			<body style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
-				<div style="border-left: 4px solid #4CAF50; padding: 20px;">
-					<h2 style="color: #333; margin-top: 0;">🔄 Transaction Notification</h2>
-					<div style="line-height: 1.6; color: #666;">
-						%s
-					</div>
-				</div>
-			</body>
-			</html>
-		`, content)
-	case "newsletter":
-		return fmt.Sprintf(`
-			<html>
-			<body style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
-				<div style="background: #2196F3; color: white; padding: 20px; text-align: center;">
-					<h1 style="margin: 0;">📰 Newsletter</h1>
-				</div>
-				<div style="padding: 20px; background: white;">
-					%s
-				</div>
-			</body>
-			</html>
-		`, content)
-	case "notification":
-		return fmt.Sprintf(`
-			<html>
-			<body style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
-				<div style="background: #FF9800; color: white; padding: 15px; border-radius: 4px;">
-					<h3 style="margin: 0;">🔔 Notification</h3>
-				</div>
-				<div style="padding: 20px; border: 1px solid #ddd; border-top: none;">
-					%s
-				</div>
-			</body>
-			</html>
-		`, content)
-	default:
-		return fmt.Sprintf(`
-			<html>
-			<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333;">
-				%s
-			</body>
-			</html>
-		`, content)
+// send calls the provider and records its latency under operation.
+func (ea *EmailActivity) send(operation string, params *resend.SendEmailRequest) (*resend.SendEmailResponse, error) {
+	start := time.Now()
+	sent, err := ea.resendClient.Emails.Send(params)
+	metrics.ObserveProvider(operation, start, err)
+	return sent, err
+}
+
+// prepareHTML runs the email-client compatibility pipeline on an outgoing
+// body. A processing failure is logged and the original body is sent.
+func (ea *EmailActivity) prepareHTML(body string) string {
+	processed, err := emailhtml.Process(body, emailhtml.Options{BaseURL: ea.assetBase})
+	if err != nil {
+		ea.logger.Warn("Failed to post-process email html", "error", err)
+		return body
 	}
+	return processed
 }
+
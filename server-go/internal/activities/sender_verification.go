@@ -0,0 +1,55 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"time"
+
+	"email-tracking-server/internal/metrics"
+
+	"github.com/resend/resend-go/v2"
+	"go.temporal.io/sdk/activity"
+)
+
+// SenderVerificationData is the input for the sender verification workflow.
+type SenderVerificationData struct {
+	IdentityID  string `json:"identityId"`
+	TenantID    string `json:"tenantId"`
+	Email       string `json:"email"`
+	DisplayName string `json:"displayName,omitempty"`
+	VerifyURL   string `json:"verifyUrl"`
+}
+
+// SendSenderVerificationEmail sends the ownership confirmation link to a
+// newly registered From address. It is always sent from the system address.
+func (ea *EmailActivity) SendSenderVerificationEmail(ctx context.Context, data SenderVerificationData) (*SendEmailResult, error) {
+	metrics.ActivityAttempt("SendSenderVerificationEmail", activity.GetInfo(ctx).Attempt)
+	logger := ea.logger.WithContext(ctx)
+	logger.Info("Starting sender verification email activity", "identity_id", data.IdentityID)
+
+	body := fmt.Sprintf(`<html><body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333;">
+<p>Someone asked to send email as <strong>%s</strong> from the Authentik Email System.</p>
+<p>If this was you, confirm that you own this address:</p>
+<p><a href="%s" style="display:inline-block;padding:10px 16px;background:#4f46e5;color:white;border-radius:6px;text-decoration:none;">Verify sender address</a></p>
+<p>If you did not request this, you can ignore this email.</p>
+</body></html>`, html.EscapeString(data.Email), html.EscapeString(data.VerifyURL))
+
+	activity.RecordHeartbeat(ctx, "Sending sender verification email via Resend")
+	params := &resend.SendEmailRequest{
+		From:    ea.fromEmail,
+		To:      []string{data.Email},
+		Subject: "Verify your sender address",
+		Html:    ea.prepareHTML(body),
+		Text:    HTMLToText(body),
+	}
+
+	sent, err := ea.send("sender_verification", params)
+	if err != nil {
+		logger.Error("Failed to send sender verification email", "error", err, "identity_id", data.IdentityID)
+		return &SendEmailResult{EmailID: data.IdentityID, Status: "failed", SentAt: time.Now(), Error: err.Error()}, err
+	}
+
+	logger.Info("Sender verification email sent", "resend_id", sent.Id, "identity_id", data.IdentityID)
+	return &SendEmailResult{EmailID: data.IdentityID, ResendID: sent.Id, Status: "sent", SentAt: time.Now()}, nil
+}
@@ -0,0 +1,81 @@
+package activities
+
+import (
+	"fmt"
+	"net/url"
+
+	"email-tracking-server/internal/preferences"
+	"email-tracking-server/internal/templates"
+	"email-tracking-server/internal/tracking"
+)
+
+// categoryFor returns the subscription category of the email, or "" for
+// emails outside the preference center (transactional, notifications).
+func categoryFor(emailData EmailData) string {
+	templateType, _ := emailData.Metadata["templateType"].(string)
+	category, _ := emailData.Metadata["category"].(string)
+	return preferences.CategoryFor(templateType, category)
+}
+
+// addSubscriptionLinks gives bulk sends (those with a subscription category)
+// signed unsubscribe and preference center links. The unsubscribe link is
+// always used for the List-Unsubscribe headers; both links fill their merge
+// fields unless the campaign supplies its own. Metadata and headers are
+// copied so the workflow input is left untouched.
+func (ea *EmailActivity) addSubscriptionLinks(emailData EmailData, recipient string) (EmailData, error) {
+	if categoryFor(emailData) == "" {
+		return emailData, nil
+	}
+
+	unsubscribeURL, err := ea.signedLink("/unsubscribe", tracking.PurposeUnsubscribe, emailData, recipient)
+	if err != nil {
+		return emailData, err
+	}
+	preferencesURL, err := ea.signedLink("/preferences", tracking.PurposePreferences, emailData, recipient)
+	if err != nil {
+		return emailData, err
+	}
+
+	metadata := make(map[string]interface{}, len(emailData.Metadata)+2)
+	for k, v := range emailData.Metadata {
+		metadata[k] = v
+	}
+	if existing, _ := metadata[templates.MetadataUnsubscribeURL].(string); existing == "" {
+		metadata[templates.MetadataUnsubscribeURL] = unsubscribeURL
+	}
+	if existing, _ := metadata[templates.MetadataPreferencesURL].(string); existing == "" {
+		metadata[templates.MetadataPreferencesURL] = preferencesURL
+	}
+
+	headers := make(map[string]string, len(emailData.Headers)+2)
+	for k, v := range emailData.Headers {
+		headers[k] = v
+	}
+	headers["List-Unsubscribe"] = "<" + unsubscribeURL + ">"
+	headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+
+	emailData.Metadata = metadata
+	emailData.Headers = headers
+	return emailData, nil
+}
+
+// signedLink builds a link to a public server page carrying a token that
+// identifies the tenant and recipient.
+func (ea *EmailActivity) signedLink(path, purpose string, emailData EmailData, recipient string) (string, error) {
+	token, err := tracking.Sign(ea.jwtSecret, tracking.Claims{
+		EntryID:   emailData.ID,
+		TenantID:  emailData.TenantID,
+		Recipient: recipient,
+		Purpose:   purpose,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign %s token: %w", purpose, err)
+	}
+
+	// approveBase is the HTTP server's public URL
+	base := ea.approveBase
+	if base == "" {
+		base = "https://tengine.zendwise.work"
+	}
+	return fmt.Sprintf("%s%s?token=%s", base, path, url.QueryEscape(token)), nil
+}
@@ -0,0 +1,68 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+
+	"email-tracking-server/internal/suppression"
+)
+
+// checkSuppressions asks the server which addresses of this send are
+// suppressed. It returns the suppression entry when the primary recipient is
+// suppressed; suppressed CC/BCC addresses are dropped from the returned lists
+// instead. An unconfigured or unreachable internal API is an error, so the
+// send is retried rather than made without the check.
+func (ea *EmailActivity) checkSuppressions(ctx context.Context, emailData EmailData, recipient string) ([]string, []string, *suppression.Entry, error) {
+	if !ea.server.Enabled() {
+		return nil, nil, nil, fmt.Errorf("failed to check suppression list: internal API not configured")
+	}
+
+	emails := append([]string{recipient}, emailData.Cc...)
+	emails = append(emails, emailData.Bcc...)
+	suppressed, err := ea.server.CheckSuppressions(ctx, emailData.TenantID, emails)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to check suppression list: %w", err)
+	}
+	if len(suppressed) == 0 {
+		return emailData.Cc, emailData.Bcc, nil, nil
+	}
+
+	if normalized, err := suppression.Normalize(recipient); err == nil {
+		if entry, ok := suppressed[normalized]; ok {
+			return nil, nil, &entry, nil
+		}
+	}
+	return withoutSuppressed(emailData.Cc, suppressed), withoutSuppressed(emailData.Bcc, suppressed), nil, nil
+}
+
+func withoutSuppressed(addrs []string, suppressed map[string]suppression.Entry) []string {
+	var kept []string
+	for _, addr := range addrs {
+		if normalized, err := suppression.Normalize(addr); err == nil {
+			if _, ok := suppressed[normalized]; ok {
+				continue
+			}
+		}
+		kept = append(kept, addr)
+	}
+	return kept
+}
+
+// optedOut reports whether the recipient opted out of the email's
+// subscription category in the preference center. Emails without a category
+// are always sent. Like the suppression check it fails rather than sending
+// when the internal API is not configured.
+func (ea *EmailActivity) optedOut(ctx context.Context, emailData EmailData, recipient string) (string, bool, error) {
+	category := categoryFor(emailData)
+	if category == "" {
+		return category, false, nil
+	}
+	if !ea.server.Enabled() {
+		return category, false, fmt.Errorf("failed to check subscription preferences: internal API not configured")
+	}
+	out, err := ea.server.OptedOut(ctx, emailData.TenantID, recipient, category)
+	if err != nil {
+		return category, false, fmt.Errorf("failed to check subscription preferences: %w", err)
+	}
+	return category, out, nil
+}
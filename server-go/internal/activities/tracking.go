@@ -0,0 +1,108 @@
+package activities
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"email-tracking-server/internal/templates"
+	"email-tracking-server/internal/tracking"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// trackingEnabled reports whether a tracking feature applies to this send.
+// Tracking is off when no tracking base URL is configured, for test sends,
+// and when the campaign sets the metadata flag (trackOpens, trackClicks) to
+// false.
+func (ea *EmailActivity) trackingEnabled(emailData EmailData, flag string) bool {
+	if ea.trackingBase == "" || emailData.Test {
+		return false
+	}
+	if v, ok := emailData.Metadata[flag].(bool); ok && !v {
+		return false
+	}
+	return true
+}
+
+// addOpenPixel appends the signed open-tracking pixel to the end of the body.
+func (ea *EmailActivity) addOpenPixel(body string, emailData EmailData, recipient string) (string, error) {
+	token, err := tracking.Sign(ea.jwtSecret, tracking.Claims{
+		EntryID:   emailData.ID,
+		Recipient: recipient,
+		Purpose:   tracking.PurposeOpen,
+	})
+	if err != nil {
+		return body, fmt.Errorf("failed to sign open tracking token: %w", err)
+	}
+	pixel := fmt.Sprintf(`<img src="%s/t/o/%s.gif" width="1" height="1" alt="" style="display:block;width:1px;height:1px;border:0;">`,
+		html.EscapeString(ea.trackingBase), html.EscapeString(token))
+	return insertBeforeBodyEnd(body, pixel), nil
+}
+
+// rewriteLinks points every http(s) link at the signed click redirect. The
+// unsubscribe link is left alone so it keeps working if tracking is turned
+// off or the token secret rotates.
+func (ea *EmailActivity) rewriteLinks(body string, emailData EmailData, recipient string) (string, error) {
+	root, err := xhtml.Parse(strings.NewReader(body))
+	if err != nil {
+		return body, fmt.Errorf("failed to parse html: %w", err)
+	}
+
+	unsubscribe, _ := emailData.Metadata[templates.MetadataUnsubscribeURL].(string)
+	index := 0
+	var rewrite func(*xhtml.Node) error
+	rewrite = func(n *xhtml.Node) error {
+		if n.Type == xhtml.ElementNode && n.DataAtom == atom.A {
+			for i, a := range n.Attr {
+				if a.Key != "href" {
+					continue
+				}
+				href := strings.TrimSpace(a.Val)
+				if !tracking.Redirectable(href) || (unsubscribe != "" && href == unsubscribe) {
+					break
+				}
+				token, err := tracking.Sign(ea.jwtSecret, tracking.Claims{
+					EntryID:   emailData.ID,
+					Recipient: recipient,
+					Purpose:   tracking.PurposeClick,
+					Link:      index,
+					URL:       href,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to sign click tracking token: %w", err)
+				}
+				n.Attr[i].Val = ea.trackingBase + "/t/c/" + url.PathEscape(token)
+				index++
+				break
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if err := rewrite(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := rewrite(root); err != nil {
+		return body, err
+	}
+
+	var buf bytes.Buffer
+	if err := xhtml.Render(&buf, root); err != nil {
+		return body, fmt.Errorf("failed to render html: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// insertBeforeBodyEnd places snippet just before </body>, or at the end of
+// the document when there is no body end tag.
+func insertBeforeBodyEnd(doc, snippet string) string {
+	if i := strings.LastIndex(strings.ToLower(doc), "</body>"); i >= 0 {
+		return doc[:i] + snippet + doc[i:]
+	}
+	return doc + snippet
+}
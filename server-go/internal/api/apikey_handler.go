@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"email-tracking-server/internal/apikeys"
+	"email-tracking-server/pkg/logger"
+
+	"github.com/gorilla/mux"
+)
+
+type APIKeyHandler struct {
+	store  *apikeys.Store
+	logger *logger.Logger
+}
+
+type APIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+func NewAPIKeyHandler(store *apikeys.Store, log *logger.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		store:  store,
+		logger: log,
+	}
+}
+
+// CreateAPIKey issues a key limited to the requested scopes. A caller can
+// only grant scopes it holds itself. The full key is in the response only.
+func (ah *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+	tenantID := principal.TenantID
+	logger := ah.logger.WithContext(r.Context())
+
+	var req APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Scopes) == 0 {
+		http.Error(w, "at least one scope is required", http.StatusBadRequest)
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !containsScope(adminScopes, scope) {
+			http.Error(w, "unknown scope: "+scope, http.StatusBadRequest)
+			return
+		}
+		if !principal.HasScope(scope) {
+			http.Error(w, "cannot grant scope not held by caller: "+scope, http.StatusForbidden)
+			return
+		}
+	}
+
+	key, raw, err := ah.store.Create(tenantID, userID, req.Name, req.Scopes)
+	if err != nil {
+		logger.Error("Failed to create API key", "error", err)
+		http.Error(w, "failed to create API key", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Created API key", "api_key_id", key.ID, "tenant_id", tenantID, "scopes", key.Scopes)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"apiKey": key,
+		"key":    raw,
+	})
+}
+
+func (ah *APIKeyHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	tenantID := principal.TenantID
+
+	keys := ah.store.List(tenantID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"apiKeys": keys,
+		"count":   len(keys),
+	})
+}
+
+func (ah *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	tenantID := principal.TenantID
+	id := mux.Vars(r)["id"]
+
+	if _, err := ah.store.Revoke(tenantID, id); err != nil {
+		if errors.Is(err, apikeys.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ah.logger.WithContext(r.Context()).Info("Revoked API key", "api_key_id", id, "tenant_id", tenantID)
+	w.WriteHeader(http.StatusNoContent)
+}
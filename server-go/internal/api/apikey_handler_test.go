@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"email-tracking-server/internal/apikeys"
+	"email-tracking-server/internal/auth"
+	"email-tracking-server/pkg/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// withPrincipal serves next as a tenant-1 user holding scopes.
+func withPrincipal(next http.HandlerFunc, scopes []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := auth.WithPrincipal(r.Context(), auth.Principal{
+			UserID:   "user-1",
+			TenantID: "tenant-1",
+			Scopes:   scopes,
+		})
+		next(w, r.WithContext(ctx))
+	})
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		body   string
+		want   int
+	}{
+		{"within caller's scopes", adminScopes, `{"name":"ci","scopes":["emails:read","emails:write"]}`, http.StatusCreated},
+		{"narrower than caller", managerScopes, `{"name":"ci","scopes":["emails:read"]}`, http.StatusCreated},
+		{"scope not held", employeeScopes, `{"name":"ci","scopes":["privacy"]}`, http.StatusForbidden},
+		{"one of several not held", managerScopes, `{"name":"ci","scopes":["emails:read","apikeys"]}`, http.StatusForbidden},
+		{"unknown scope", adminScopes, `{"name":"ci","scopes":["everything"]}`, http.StatusBadRequest},
+		{"no scopes", adminScopes, `{"name":"ci","scopes":[]}`, http.StatusBadRequest},
+		{"no name", adminScopes, `{"name":"  ","scopes":["emails:read"]}`, http.StatusBadRequest},
+		{"invalid JSON", adminScopes, `{"name":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := apikeys.NewStore()
+			ah := NewAPIKeyHandler(store, logger.New("error", "text"))
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/api-keys", strings.NewReader(tt.body))
+			withPrincipal(ah.CreateAPIKey, tt.scopes).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want != http.StatusCreated {
+				if keys := store.List("tenant-1"); len(keys) != 0 {
+					t.Errorf("rejected request created %d keys", len(keys))
+				}
+				return
+			}
+
+			var resp struct {
+				APIKey apikeys.Key `json:"apiKey"`
+				Key    string      `json:"key"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			var requested APIKeyRequest
+			json.Unmarshal([]byte(tt.body), &requested)
+
+			key, err := store.Authenticate(resp.Key)
+			if err != nil {
+				t.Fatalf("returned key does not authenticate: %v", err)
+			}
+			if key.TenantID != "tenant-1" || !reflect.DeepEqual(key.Scopes, requested.Scopes) {
+				t.Errorf("key = %+v, want tenant-1 with scopes %v", key, requested.Scopes)
+			}
+		})
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	store := apikeys.NewStore()
+	own, raw, _ := store.Create("tenant-1", "user-1", "ci", []string{ScopeEmailsRead})
+	other, _, _ := store.Create("tenant-2", "user-2", "ci", []string{ScopeEmailsRead})
+	ah := NewAPIKeyHandler(store, logger.New("error", "text"))
+
+	router := mux.NewRouter()
+	router.Handle("/api/api-keys/{id}", withPrincipal(ah.RevokeAPIKey, adminScopes)).Methods("DELETE")
+
+	tests := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"other tenant's key", other.ID, http.StatusNotFound},
+		{"unknown key", "key_unknown", http.StatusNotFound},
+		{"own key", own.ID, http.StatusNoContent},
+		{"already revoked", own.ID, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("DELETE", "/api/api-keys/"+tt.id, nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	if _, err := store.Authenticate(raw); !errors.Is(err, apikeys.ErrRevoked) {
+		t.Errorf("Authenticate after revoke error = %v, want %v", err, apikeys.ErrRevoked)
+	}
+}
+
+// TestAPIKeyAuthentication sends keys through JWTMiddleware and Authorize: a
+// key acts in its tenant with exactly its own scopes.
+func TestAPIKeyAuthentication(t *testing.T) {
+	store := apikeys.NewStore()
+	_, readKey, _ := store.Create("tenant-1", "user-1", "reader", []string{ScopeEmailsRead})
+	revoked, revokedKey, _ := store.Create("tenant-1", "user-1", "old", []string{ScopeEmailsRead})
+	store.Revoke("tenant-1", revoked.ID)
+
+	eh := &EmailHandler{apiKeys: store, logger: logger.New("error", "text")}
+	router := mux.NewRouter()
+	router.Use(eh.JWTMiddleware, Authorize)
+	router.HandleFunc("/api/email-tracking", func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.FromContext(r.Context())
+		if principal.TenantID != "tenant-1" || !strings.HasPrefix(principal.UserID, "apikey:") {
+			t.Errorf("principal = %+v", principal)
+		}
+		w.WriteHeader(http.StatusOK)
+	}).Methods("GET")
+	router.HandleFunc("/api/templates", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods("POST")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header string
+		value  string
+		want   int
+	}{
+		{"X-API-Key within scope", "GET", "/api/email-tracking", "X-API-Key", readKey, http.StatusOK},
+		{"bearer key within scope", "GET", "/api/email-tracking", "Authorization", "Bearer " + readKey, http.StatusOK},
+		{"key beyond its scopes", "POST", "/api/templates", "X-API-Key", readKey, http.StatusForbidden},
+		{"revoked key", "GET", "/api/email-tracking", "X-API-Key", revokedKey, http.StatusUnauthorized},
+		{"tampered key", "GET", "/api/email-tracking", "X-API-Key", readKey + "x", http.StatusUnauthorized},
+		{"malformed key", "GET", "/api/email-tracking", "X-API-Key", "etk_", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(tt.header, tt.value)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"email-tracking-server/internal/activities"
+	"email-tracking-server/internal/blobstore"
+)
+
+// AttachmentUpload is an attachment as sent by the client, with base64
+// content. It is replaced by a blob reference before the entry is stored.
+type AttachmentUpload struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+// reservedHeaders are set by the send pipeline and cannot be overridden
+// through custom headers.
+var reservedHeaders = map[string]bool{
+	"from":                      true,
+	"to":                        true,
+	"cc":                        true,
+	"bcc":                       true,
+	"reply-to":                  true,
+	"subject":                   true,
+	"date":                      true,
+	"message-id":                true,
+	"content-type":              true,
+	"content-transfer-encoding": true,
+	"mime-version":              true,
+	"list-unsubscribe":          true,
+	"list-unsubscribe-post":     true,
+}
+
+// validateAddressing checks CC/BCC/Reply-To addresses and custom headers.
+func validateAddressing(cc, bcc []string, replyTo string, headers map[string]string) error {
+	for _, list := range [][]string{cc, bcc} {
+		for _, addr := range list {
+			if _, err := mail.ParseAddress(addr); err != nil {
+				return fmt.Errorf("invalid email address %q", addr)
+			}
+		}
+	}
+	if replyTo != "" {
+		if _, err := mail.ParseAddress(replyTo); err != nil {
+			return fmt.Errorf("invalid replyTo address %q", replyTo)
+		}
+	}
+	for name, value := range headers {
+		if name == "" || strings.ContainsAny(name, ": \r\n") {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if reservedHeaders[strings.ToLower(name)] {
+			return fmt.Errorf("header %q cannot be overridden", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("header %q contains a line break", name)
+		}
+	}
+	return nil
+}
+
+// storeAttachments validates uploads against the size and type limits and
+// moves their content into the blob store, returning the references that
+// travel with the workflow.
+func storeAttachments(store blobstore.Store, uploads []AttachmentUpload) ([]activities.Attachment, error) {
+	if len(uploads) == 0 {
+		return nil, nil
+	}
+	if len(uploads) > activities.MaxAttachments {
+		return nil, fmt.Errorf("at most %d attachments are allowed", activities.MaxAttachments)
+	}
+
+	var total int64
+	decoded := make([][]byte, len(uploads))
+	types := make([]string, len(uploads))
+	for i, u := range uploads {
+		if u.Filename == "" || strings.ContainsAny(u.Filename, "/\\\r\n") {
+			return nil, fmt.Errorf("attachment %d has an invalid filename", i+1)
+		}
+
+		contentType, _, err := mime.ParseMediaType(u.ContentType)
+		if err != nil || !activities.AllowedAttachmentTypes[contentType] {
+			return nil, fmt.Errorf("attachment %q has unsupported content type %q", u.Filename, u.ContentType)
+		}
+
+		data, err := base64.StdEncoding.DecodeString(u.Content)
+		if err != nil {
+			return nil, fmt.Errorf("attachment %q content is not valid base64", u.Filename)
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("attachment %q is empty", u.Filename)
+		}
+		if len(data) > activities.MaxAttachmentSize {
+			return nil, fmt.Errorf("attachment %q exceeds the %dMB limit", u.Filename, activities.MaxAttachmentSize>>20)
+		}
+		if !sniffMatches(contentType, http.DetectContentType(data)) {
+			return nil, fmt.Errorf("attachment %q content does not match declared type %s", u.Filename, contentType)
+		}
+
+		total += int64(len(data))
+		decoded[i] = data
+		types[i] = contentType
+	}
+	if total > activities.MaxTotalAttachmentSize {
+		return nil, fmt.Errorf("attachments exceed the %dMB total limit", activities.MaxTotalAttachmentSize>>20)
+	}
+
+	attachments := make([]activities.Attachment, 0, len(uploads))
+	for i, u := range uploads {
+		key, err := store.Put(decoded[i])
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, activities.Attachment{
+			Filename:    u.Filename,
+			ContentType: types[i],
+			Size:        int64(len(decoded[i])),
+			BlobKey:     key,
+		})
+	}
+	return attachments, nil
+}
+
+// sniffMatches compares a declared type with the type detected from content.
+// Office documents are zip containers and text types sniff as text/plain.
+func sniffMatches(declared, sniffed string) bool {
+	switch {
+	case strings.HasPrefix(declared, "text/"):
+		return strings.HasPrefix(sniffed, "text/plain")
+	case strings.HasPrefix(declared, "application/vnd.openxmlformats"):
+		return sniffed == "application/zip"
+	default:
+		return sniffed == declared
+	}
+}
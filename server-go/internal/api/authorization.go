@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"email-tracking-server/internal/auth"
+
+	"github.com/gorilla/mux"
+)
+
+// Scopes guarding the protected API.
+const (
+	ScopeEmailsRead        = "emails:read"
+	ScopeEmailsWrite       = "emails:write"
+	ScopeTemplatesRead     = "templates:read"
+	ScopeTemplatesWrite    = "templates:write"
+	ScopeSuppressionsRead  = "suppressions:read"
+	ScopeSuppressionsWrite = "suppressions:write"
+	ScopeSendersRead       = "senders:read"
+	ScopeSendersWrite      = "senders:write"
+	ScopePrivacy           = "privacy"
+	ScopeAPIKeys           = "apikeys"
+	// ScopeTenantAdmin grants access to every tracking entry in the tenant
+	// rather than only the user's own.
+	ScopeTenantAdmin = "tenant:admin"
+)
+
+// Roles issued by the main application.
+const (
+	RoleOwner         = "Owner"
+	RoleAdministrator = "Administrator"
+	RoleManager       = "Manager"
+	RoleEmployee      = "Employee"
+)
+
+var employeeScopes = []string{
+	ScopeEmailsRead, ScopeEmailsWrite,
+	ScopeTemplatesRead, ScopeTemplatesWrite,
+	ScopeSuppressionsRead,
+	ScopeSendersRead,
+}
+
+var managerScopes = append(append([]string{}, employeeScopes...),
+	ScopeSuppressionsWrite,
+	ScopeSendersWrite,
+)
+
+var adminScopes = append(append([]string{}, managerScopes...),
+	ScopePrivacy,
+	ScopeAPIKeys,
+	ScopeTenantAdmin,
+)
+
+// roleScopes are the scopes each role is granted.
+var roleScopes = map[string][]string{
+	RoleOwner:         adminScopes,
+	RoleAdministrator: adminScopes,
+	RoleManager:       managerScopes,
+	RoleEmployee:      employeeScopes,
+}
+
+// routeScopes maps each protected route, by method and path template, to
+// the scope it requires. Routes missing from the table are refused.
+var routeScopes = map[string]string{
+	"POST /api/email-tracking":                             ScopeEmailsWrite,
+	"GET /api/email-tracking":                              ScopeEmailsRead,
+	"POST /api/email-tracking/test-send":                   ScopeEmailsWrite,
+	"GET /api/email-tracking/{id}":                         ScopeEmailsRead,
+	"GET /api/email-tracking/{id}/events":                  ScopeEmailsRead,
+	"PUT /api/email-tracking/{id}":                         ScopeEmailsWrite,
+	"DELETE /api/email-tracking/{id}":                      ScopeEmailsWrite,
+	"POST /api/templates":                                  ScopeTemplatesWrite,
+	"GET /api/templates":                                   ScopeTemplatesRead,
+	"POST /api/templates/preview":                          ScopeTemplatesRead,
+	"GET /api/templates/{id}":                              ScopeTemplatesRead,
+	"DELETE /api/templates/{id}":                           ScopeTemplatesWrite,
+	"POST /api/templates/{id}/versions":                    ScopeTemplatesWrite,
+	"GET /api/templates/{id}/versions/{version}":           ScopeTemplatesRead,
+	"POST /api/templates/{id}/publish":                     ScopeTemplatesWrite,
+	"GET /api/suppressions":                                ScopeSuppressionsRead,
+	"POST /api/suppressions":                               ScopeSuppressionsWrite,
+	"DELETE /api/suppressions/{email}":                     ScopeSuppressionsWrite,
+	"POST /api/privacy/export":                             ScopePrivacy,
+	"POST /api/privacy/erase":                              ScopePrivacy,
+	"GET /api/privacy/audit":                               ScopePrivacy,
+	"POST /api/api-keys":                                   ScopeAPIKeys,
+	"GET /api/api-keys":                                    ScopeAPIKeys,
+	"DELETE /api/api-keys/{id}":                            ScopeAPIKeys,
+	"POST /api/sender-identities":                          ScopeSendersWrite,
+	"GET /api/sender-identities":                           ScopeSendersRead,
+	"DELETE /api/sender-identities/{id}":                   ScopeSendersWrite,
+	"POST /api/sender-identities/{id}/resend-verification": ScopeSendersWrite,
+	"POST /api/sender-identities/{id}/default":             ScopeSendersWrite,
+}
+
+// EffectiveScopes returns the scopes a token grants. A known role grants its
+// scopes, narrowed to the token's scope claim when one is present. Without a
+// role the scope claim is used as is, and tokens with neither get the
+// employee scopes they had before roles existed. Unknown roles grant nothing.
+func (c *JWTClaims) EffectiveScopes() []string {
+	requested := strings.Fields(c.Scope)
+	if c.Role == "" {
+		if len(requested) > 0 {
+			return requested
+		}
+		return employeeScopes
+	}
+
+	granted, ok := roleScopes[c.Role]
+	if !ok {
+		return nil
+	}
+	if len(requested) == 0 {
+		return granted
+	}
+	var result []string
+	for _, scope := range requested {
+		if containsScope(granted, scope) {
+			result = append(result, scope)
+		}
+	}
+	return result
+}
+
+// Authorize rejects requests whose scopes do not include the one required
+// for the matched route. It runs after the authentication middleware.
+func Authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		required, ok := routeScopes[r.Method+" "+template]
+		if !ok || !HasScope(r.Context(), required) {
+			http.Error(w, "Insufficient scope", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HasScope reports whether the authenticated caller was granted scope.
+func HasScope(ctx context.Context, scope string) bool {
+	principal, err := auth.FromContext(ctx)
+	return err == nil && principal.HasScope(scope)
+}
+
+// requirePrincipal returns the authenticated caller. If the authentication
+// middleware did not run it answers 401 and reports false.
+func requirePrincipal(w http.ResponseWriter, r *http.Request) (auth.Principal, bool) {
+	principal, err := auth.FromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return auth.Principal{}, false
+	}
+	return principal, true
+}
+
+// canAccessEntry reports whether the caller may see or change an entry: its
+// owner, or a tenant admin of the same tenant.
+func canAccessEntry(principal auth.Principal, entry EmailTrackingEntry) bool {
+	if entry.TenantID != principal.TenantID {
+		return false
+	}
+	return entry.UserID == principal.UserID || principal.HasScope(ScopeTenantAdmin)
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"email-tracking-server/internal/auth"
+
+	"github.com/gorilla/mux"
+)
+
+func TestEffectiveScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims JWTClaims
+		want   []string
+	}{
+		{"no role or scope", JWTClaims{}, employeeScopes},
+		{"employee", JWTClaims{Role: RoleEmployee}, employeeScopes},
+		{"manager", JWTClaims{Role: RoleManager}, managerScopes},
+		{"administrator", JWTClaims{Role: RoleAdministrator}, adminScopes},
+		{"owner", JWTClaims{Role: RoleOwner}, adminScopes},
+		{"unknown role", JWTClaims{Role: "Intern"}, nil},
+		{"scope without role", JWTClaims{Scope: "emails:read privacy"}, []string{ScopeEmailsRead, ScopePrivacy}},
+		{"scope narrows role", JWTClaims{Role: RoleOwner, Scope: "emails:read"}, []string{ScopeEmailsRead}},
+		{"scope beyond role", JWTClaims{Role: RoleEmployee, Scope: "emails:read privacy"}, []string{ScopeEmailsRead}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.claims.EffectiveScopes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EffectiveScopes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRouteScopesGranted catches table entries whose scope no role holds,
+// which would lock every user out of the route.
+func TestRouteScopesGranted(t *testing.T) {
+	for route, scope := range routeScopes {
+		if !containsScope(adminScopes, scope) {
+			t.Errorf("%s requires %q, which no role is granted", route, scope)
+		}
+	}
+}
+
+var pathVar = regexp.MustCompile(`\{[^}]+\}`)
+
+// authorizeRouter registers every route in routeScopes behind Authorize, with
+// a principal holding the scopes role would get from a token.
+func authorizeRouter(role string) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := JWTClaims{UserID: "user-1", TenantID: "tenant-1", Role: role}
+			ctx := auth.WithPrincipal(r.Context(), auth.Principal{
+				UserID:   claims.UserID,
+				TenantID: claims.TenantID,
+				Role:     claims.Role,
+				Scopes:   claims.EffectiveScopes(),
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}, Authorize)
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	for route := range routeScopes {
+		method, template, _ := strings.Cut(route, " ")
+		router.HandleFunc(template, ok).Methods(method)
+	}
+	// Matched but missing from the table
+	router.HandleFunc("/api/unlisted", ok).Methods("GET")
+	return router
+}
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		role   string
+		method string
+		path   string
+		want   int
+	}{
+		{RoleEmployee, "GET", "/api/email-tracking", http.StatusOK},
+		{RoleEmployee, "POST", "/api/templates", http.StatusOK},
+		{RoleEmployee, "GET", "/api/suppressions", http.StatusOK},
+		{RoleEmployee, "DELETE", "/api/suppressions/a@example.com", http.StatusForbidden},
+		{RoleEmployee, "POST", "/api/sender-identities", http.StatusForbidden},
+		{RoleEmployee, "POST", "/api/privacy/erase", http.StatusForbidden},
+		{RoleEmployee, "GET", "/api/api-keys", http.StatusForbidden},
+		{RoleManager, "DELETE", "/api/suppressions/a@example.com", http.StatusOK},
+		{RoleManager, "POST", "/api/sender-identities/s1/default", http.StatusOK},
+		{RoleManager, "POST", "/api/privacy/export", http.StatusForbidden},
+		{RoleManager, "DELETE", "/api/api-keys/k1", http.StatusForbidden},
+		{RoleAdministrator, "POST", "/api/privacy/erase", http.StatusOK},
+		{RoleOwner, "POST", "/api/api-keys", http.StatusOK},
+		{"Intern", "GET", "/api/email-tracking", http.StatusForbidden},
+		{RoleOwner, "GET", "/api/unlisted", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role+" "+tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			authorizeRouter(tt.role).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+// TestAuthorizeRouteTable checks every route in the table against every role:
+// a request succeeds exactly when the role is granted the route's scope.
+func TestAuthorizeRouteTable(t *testing.T) {
+	for role, granted := range roleScopes {
+		router := authorizeRouter(role)
+		for route, scope := range routeScopes {
+			method, template, _ := strings.Cut(route, " ")
+			path := pathVar.ReplaceAllString(template, "x")
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+
+			want := http.StatusForbidden
+			if containsScope(granted, scope) {
+				want = http.StatusOK
+			}
+			if rec.Code != want {
+				t.Errorf("%s %s: status = %d, want %d", role, route, rec.Code, want)
+			}
+		}
+	}
+}
+
+func TestAuthorizeWithoutPrincipal(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(Authorize)
+	router.HandleFunc("/api/email-tracking", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods("GET")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/email-tracking", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
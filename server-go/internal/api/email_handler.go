@@ -2,16 +2,26 @@ package api
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"html"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"email-tracking-server/internal/activities"
+	"email-tracking-server/internal/apikeys"
+	"email-tracking-server/internal/approval"
+	"email-tracking-server/internal/auth"
+	"email-tracking-server/internal/blobstore"
 	"email-tracking-server/internal/client"
+	"email-tracking-server/internal/emailhtml"
+	"email-tracking-server/internal/events"
+	"email-tracking-server/internal/metrics"
+	"email-tracking-server/internal/senders"
+	"email-tracking-server/internal/templates"
+	"email-tracking-server/internal/tracking"
 	"email-tracking-server/pkg/logger"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -23,24 +33,52 @@ type EmailHandler struct {
 	temporalClient *client.TemporalClient
 	taskQueue      string
 	jwtSecret      string
+	approvals      *approval.Signer
+	verifier       *UserTokenVerifier
+	apiKeys        *apikeys.Store
 	logger         *logger.Logger
+	templates      *templates.Registry
+	policy         *emailhtml.Policy
+	blobs          blobstore.Store
+	senders        *senders.Store
+	opens          *tracking.Deduper
+	clicks         *tracking.Deduper
+	events         *events.Log
+	// mu guards trackingStore; see entries.go
+	mu sync.RWMutex
 	// In-memory store for demo purposes - in production use a database
 	trackingStore map[string]EmailTrackingEntry
-	// Store for used approval tokens to prevent reuse
+	// Store for used approval tokens to prevent reuse, guarded by tokensMu
+	tokensMu   sync.Mutex
 	usedTokens map[string]time.Time
 }
 
 type EmailTrackingEntry struct {
-	ID               string                 `json:"id"`
-	UserID           string                 `json:"userId"`
-	TenantID         string                 `json:"tenantId"`
-	EmailID          string                 `json:"emailId"`
-	Status           string                 `json:"status"`
-	Timestamp        time.Time              `json:"timestamp"`
-	ScheduledAt      *time.Time             `json:"scheduledAt,omitempty"`
-	Timezone         string                 `json:"timezone,omitempty"`
-	TemporalWorkflow string                 `json:"temporalWorkflow,omitempty"`
-	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+	ID                string                  `json:"id"`
+	UserID            string                  `json:"userId"`
+	TenantID          string                  `json:"tenantId"`
+	EmailID           string                  `json:"emailId"`
+	Status            string                  `json:"status"`
+	Timestamp         time.Time               `json:"timestamp"`
+	ScheduledAt       *time.Time              `json:"scheduledAt,omitempty"`
+	Timezone          string                  `json:"timezone,omitempty"`
+	TemporalWorkflow  string                  `json:"temporalWorkflow,omitempty"`
+	TemplateID        string                  `json:"templateId,omitempty"`
+	TemplateVersion   int                     `json:"templateVersion,omitempty"`
+	SenderIdentityID  string                  `json:"senderIdentityId,omitempty"`
+	Test              bool                    `json:"test,omitempty"`
+	Cc                []string                `json:"cc,omitempty"`
+	Bcc               []string                `json:"bcc,omitempty"`
+	ReplyTo           string                  `json:"replyTo,omitempty"`
+	Headers           map[string]string       `json:"headers,omitempty"`
+	Attachments       []activities.Attachment `json:"attachments,omitempty"`
+	OpenCount         int                     `json:"openCount"`
+	MachineOpenCount  int                     `json:"machineOpenCount,omitempty"`
+	FirstOpenedAt     *time.Time              `json:"firstOpenedAt,omitempty"`
+	LastOpenedAt      *time.Time              `json:"lastOpenedAt,omitempty"`
+	ClickCount        int                     `json:"clickCount"`
+	MachineClickCount int                     `json:"machineClickCount,omitempty"`
+	Metadata          map[string]interface{}  `json:"metadata,omitempty"`
 }
 
 type EmailTrackingRequest struct {
@@ -49,21 +87,42 @@ type EmailTrackingRequest struct {
 	ScheduledAt      string                 `json:"scheduledAt,omitempty"`
 	Timezone         string                 `json:"timezone,omitempty"`
 	TemporalWorkflow string                 `json:"temporalWorkflow,omitempty"`
+	TemplateID       string                 `json:"templateId,omitempty"`
+	TemplateVersion  int                    `json:"templateVersion,omitempty"`
+	SenderIdentityID string                 `json:"senderIdentityId,omitempty"`
+	Cc               []string               `json:"cc,omitempty"`
+	Bcc              []string               `json:"bcc,omitempty"`
+	ReplyTo          string                 `json:"replyTo,omitempty"`
+	Headers          map[string]string      `json:"headers,omitempty"`
+	Attachments      []AttachmentUpload     `json:"attachments,omitempty"`
 	Metadata         map[string]interface{} `json:"metadata,omitempty"`
 }
 
 type JWTClaims struct {
 	UserID   string `json:"userId"`
 	TenantID string `json:"tenantId"`
+	Email    string `json:"email,omitempty"`
+	Role     string `json:"role,omitempty"`
+	Scope    string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func NewEmailHandler(temporalClient *client.TemporalClient, taskQueue string, jwtSecret string, log *logger.Logger) *EmailHandler {
+func NewEmailHandler(temporalClient *client.TemporalClient, taskQueue string, jwtSecret string, approvals *approval.Signer, templateRegistry *templates.Registry, policy *emailhtml.Policy, blobs blobstore.Store, senderStore *senders.Store, eventLog *events.Log, verifier *UserTokenVerifier, apiKeys *apikeys.Store, log *logger.Logger) *EmailHandler {
 	return &EmailHandler{
 		temporalClient: temporalClient,
 		taskQueue:      taskQueue,
 		jwtSecret:      jwtSecret,
+		approvals:      approvals,
+		verifier:       verifier,
+		apiKeys:        apiKeys,
 		logger:         log,
+		templates:      templateRegistry,
+		policy:         policy,
+		blobs:          blobs,
+		senders:        senderStore,
+		opens:          tracking.NewDeduper(),
+		clicks:         tracking.NewDeduper(),
+		events:         eventLog,
 		trackingStore:  make(map[string]EmailTrackingEntry),
 		usedTokens:     make(map[string]time.Time),
 	}
@@ -74,6 +133,16 @@ func (eh *EmailHandler) JWTMiddleware(next http.Handler) http.Handler {
 		logger := eh.logger.WithContext(r.Context())
 		logger.Info("Processing JWT authentication", "path", r.URL.Path)
 
+		// Integrations authenticate with an API key instead of a user JWT
+		if key := r.Header.Get("X-API-Key"); key != "" {
+			eh.authenticateAPIKey(w, r, next, key)
+			return
+		}
+		if key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); apikeys.IsKey(key) {
+			eh.authenticateAPIKey(w, r, next, key)
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
 			logger.Warn("No Authorization header provided")
@@ -88,39 +157,57 @@ func (eh *EmailHandler) JWTMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(eh.jwtSecret), nil
-		})
-
+		claims, err := eh.verifier.Verify(tokenString)
 		if err != nil {
 			logger.Error("Token validation error", "error", err)
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
-		claims, ok := token.Claims.(*JWTClaims)
-		if !ok || !token.Valid {
-			logger.Error("Invalid token claims")
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
+		scopes := claims.EffectiveScopes()
+		logger.Info("User authenticated", "user_id", claims.UserID, "tenant_id", claims.TenantID, "role", claims.Role)
+
+		ctx := auth.WithPrincipal(r.Context(), auth.Principal{
+			UserID:   claims.UserID,
+			TenantID: claims.TenantID,
+			Email:    claims.Email,
+			Role:     claims.Role,
+			Scopes:   scopes,
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		logger.Info("User authenticated", "user_id", claims.UserID, "tenant_id", claims.TenantID)
+// authenticateAPIKey serves the request as the API key's tenant with the
+// key's scopes. The key ID stands in for the user ID.
+func (eh *EmailHandler) authenticateAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, raw string) {
+	logger := eh.logger.WithContext(r.Context())
 
-		// Add user info to request context
-		ctx := context.WithValue(r.Context(), "userID", claims.UserID)
-		ctx = context.WithValue(ctx, "tenantID", claims.TenantID)
+	key, err := eh.apiKeys.Authenticate(raw)
+	if err != nil {
+		logger.Warn("API key rejected", "error", err)
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return
+	}
 
-		next.ServeHTTP(w, r.WithContext(ctx))
+	logger.Info("API key authenticated", "api_key_id", key.ID, "tenant_id", key.TenantID)
+
+	ctx := auth.WithPrincipal(r.Context(), auth.Principal{
+		UserID:   "apikey:" + key.ID,
+		TenantID: key.TenantID,
+		Scopes:   key.Scopes,
+		APIKeyID: key.ID,
 	})
+	next.ServeHTTP(w, r.WithContext(ctx))
 }
 
 func (eh *EmailHandler) CreateEmailTracking(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
-	tenantID := r.Context().Value("tenantID").(string)
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+	tenantID := principal.TenantID
 	logger := eh.logger.WithContext(r.Context())
 
 	var req EmailTrackingRequest
@@ -136,6 +223,73 @@ func (eh *EmailHandler) CreateEmailTracking(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// Pin the template version now so later publishes don't change what this
+	// entry sends
+	var pinned *templates.Version
+	if req.TemplateID != "" {
+		tmpl, version, err := eh.templates.Resolve(tenantID, req.TemplateID, req.TemplateVersion)
+		if err != nil {
+			logger.Error("Failed to resolve template", "error", err, "template_id", req.TemplateID, "template_version", req.TemplateVersion)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.TemplateVersion = version.Number
+		pinned = &version
+		if req.Metadata == nil {
+			req.Metadata = make(map[string]interface{})
+		}
+		if _, ok := req.Metadata["templateType"]; !ok && tmpl.Type != "" {
+			req.Metadata["templateType"] = tmpl.Type
+		}
+	}
+
+	// Validate merge fields now so a bad template is rejected here rather than
+	// failing inside the workflow at send time
+	recipient, _ := req.Metadata["recipient"].(string)
+	mergeData := templates.MergeDataFromMetadata(recipient, req.Metadata)
+	if content, ok := req.Metadata["content"].(string); ok && content != "" {
+		if violations := eh.policy.Check(content); len(violations) > 0 {
+			logger.Warn("Content rejected by sanitizer", "email_id", req.EmailID, "violations", len(violations))
+			writeViolations(w, violations)
+			return
+		}
+		if err := templates.Validate(content, mergeData); err != nil {
+			logger.Error("Invalid content template", "error", err, "email_id", req.EmailID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if pinned != nil {
+		if err := templates.ValidateVersion(*pinned, mergeData); err != nil {
+			logger.Error("Invalid template version", "error", err, "email_id", req.EmailID, "template_id", req.TemplateID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	subject, _ := req.Metadata["subject"].(string)
+	if subject == "" && pinned != nil {
+		subject = pinned.Subject
+	}
+	if err := templates.Validate(subject, mergeData); err != nil {
+		logger.Error("Invalid subject template", "error", err, "email_id", req.EmailID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateAddressing(req.Cc, req.Bcc, req.ReplyTo, req.Headers); err != nil {
+		logger.Error("Invalid addressing", "error", err, "email_id", req.EmailID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.SenderIdentityID != "" {
+		if _, _, err := eh.senders.Resolve(tenantID, req.SenderIdentityID); err != nil {
+			logger.Error("Invalid sender identity", "error", err, "sender_identity_id", req.SenderIdentityID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	// If reviewer approval is required, force status to awaiting_approval to prevent immediate send
 	// This guards against clients accidentally sending queued/scheduled
 	if req.Metadata != nil {
@@ -166,6 +320,15 @@ func (eh *EmailHandler) CreateEmailTracking(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
+	// Attachment content goes to the blob store; the entry and workflow input
+	// only carry references
+	attachments, err := storeAttachments(eh.blobs, req.Attachments)
+	if err != nil {
+		logger.Error("Invalid attachments", "error", err, "email_id", req.EmailID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	entry := EmailTrackingEntry{
 		ID:               generateID(),
 		UserID:           userID,
@@ -176,10 +339,19 @@ func (eh *EmailHandler) CreateEmailTracking(w http.ResponseWriter, r *http.Reque
 		ScheduledAt:      scheduledAt,
 		Timezone:         timezone,
 		TemporalWorkflow: req.TemporalWorkflow,
+		TemplateID:       req.TemplateID,
+		TemplateVersion:  req.TemplateVersion,
+		SenderIdentityID: req.SenderIdentityID,
+		Cc:               req.Cc,
+		Bcc:              req.Bcc,
+		ReplyTo:          req.ReplyTo,
+		Headers:          req.Headers,
+		Attachments:      attachments,
 		Metadata:         req.Metadata,
 	}
 
-	eh.trackingStore[entry.ID] = entry
+	eh.putEntry(entry)
+	eh.events.Append(entry.ID, events.TypeCreated, events.UserActor(userID), map[string]interface{}{"status": entry.Status})
 
 	logger.Info("Created email tracking entry",
 		"entry_id", entry.ID,
@@ -221,7 +393,10 @@ func (eh *EmailHandler) CreateEmailTracking(w http.ResponseWriter, r *http.Reque
 		logger.Info("Routing to reviewer approval workflow", "email_id", entry.EmailID)
 		// Ensure the entry reflects awaiting_approval immediately
 		entry.Status = "awaiting_approval"
-		eh.trackingStore[entry.ID] = entry
+		eh.updateEntry(entry.ID, func(stored *EmailTrackingEntry) bool {
+			stored.Status = entry.Status
+			return true
+		})
 		go eh.startReviewerApprovalWorkflow(entry)
 	} else if isScheduled {
 		logger.Info("Routing to scheduled workflow", "email_id", entry.EmailID)
@@ -236,17 +411,128 @@ func (eh *EmailHandler) CreateEmailTracking(w http.ResponseWriter, r *http.Reque
 	json.NewEncoder(w).Encode(entry)
 }
 
-func (eh *EmailHandler) GetEmailTrackings(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
-	tenantID := r.Context().Value("tenantID").(string)
+// TestSendEmail renders the requested email and sends a single copy to the
+// authenticated user's own address through the normal workflow. The entry is
+// marked as a test and never routed through approval or scheduling.
+func (eh *EmailHandler) TestSendEmail(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+	tenantID := principal.TenantID
+	userEmail := principal.Email
+	logger := eh.logger.WithContext(r.Context())
+
+	if userEmail == "" {
+		logger.Warn("Test send requested without an email claim", "user_id", userID)
+		http.Error(w, "authenticated user has no email address", http.StatusBadRequest)
+		return
+	}
+
+	var req EmailTrackingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Invalid JSON payload", "error", err)
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	// Copy metadata so the caller's campaign data is left untouched
+	metadata := make(map[string]interface{}, len(req.Metadata)+2)
+	for k, v := range req.Metadata {
+		metadata[k] = v
+	}
+	metadata["recipient"] = userEmail
+	metadata["isTest"] = true
+	if req.EmailID != "" {
+		metadata["sourceEmailId"] = req.EmailID
+	}
+
+	var version *templates.Version
+	if req.TemplateID != "" {
+		_, resolved, err := eh.templates.Resolve(tenantID, req.TemplateID, req.TemplateVersion)
+		if err != nil {
+			logger.Error("Failed to resolve template", "error", err, "template_id", req.TemplateID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.TemplateVersion = resolved.Number
+		version = &resolved
+	}
 
-	var userEntries []EmailTrackingEntry
-	for _, entry := range eh.trackingStore {
-		if entry.UserID == userID && entry.TenantID == tenantID {
-			userEntries = append(userEntries, entry)
+	if req.SenderIdentityID != "" {
+		if _, _, err := eh.senders.Resolve(tenantID, req.SenderIdentityID); err != nil {
+			logger.Error("Invalid sender identity", "error", err, "sender_identity_id", req.SenderIdentityID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 	}
 
+	if content, ok := metadata["content"].(string); ok && content != "" {
+		if violations := eh.policy.Check(content); len(violations) > 0 {
+			writeViolations(w, violations)
+			return
+		}
+	}
+
+	// Render up front so template errors are reported to the caller
+	if _, err := activities.RenderEmail(activities.EmailData{Metadata: metadata, Template: version}, userEmail, eh.policy); err != nil {
+		logger.Error("Test send render failed", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if subject, ok := metadata["subject"].(string); ok && subject != "" {
+		metadata["subject"] = "[Test] " + subject
+	}
+
+	id := generateID()
+	entry := EmailTrackingEntry{
+		ID:               id,
+		UserID:           userID,
+		TenantID:         tenantID,
+		EmailID:          fmt.Sprintf("test-%s", id),
+		Status:           "queued",
+		Timestamp:        time.Now().UTC(),
+		TemplateID:       req.TemplateID,
+		TemplateVersion:  req.TemplateVersion,
+		SenderIdentityID: req.SenderIdentityID,
+		Test:             true,
+		Metadata:         metadata,
+	}
+	eh.putEntry(entry)
+	eh.events.Append(entry.ID, events.TypeCreated, events.UserActor(userID), map[string]interface{}{"status": entry.Status, "test": true})
+
+	logger.Info("Created test send entry", "entry_id", entry.ID, "source_email_id", req.EmailID)
+
+	go eh.startEmailWorkflow(entry)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(entry)
+}
+
+func (eh *EmailHandler) GetEmailTrackings(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+	tenantID := principal.TenantID
+
+	// Test sends are excluded unless explicitly requested so they don't skew
+	// campaign statistics built from this list
+	includeTests := r.URL.Query().Get("includeTests") == "true"
+
+	// Tenant admins see every entry in the tenant, others only their own
+	tenantWide := principal.HasScope(ScopeTenantAdmin)
+
+	userEntries := eh.findEntries(func(entry EmailTrackingEntry) bool {
+		if entry.Test && !includeTests {
+			return false
+		}
+		return entry.TenantID == tenantID && (tenantWide || entry.UserID == userID)
+	})
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"entries": userEntries,
@@ -255,19 +541,21 @@ func (eh *EmailHandler) GetEmailTrackings(w http.ResponseWriter, r *http.Request
 }
 
 func (eh *EmailHandler) GetEmailTracking(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
-	tenantID := r.Context().Value("tenantID").(string)
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
 
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	entry, exists := eh.trackingStore[id]
+	entry, exists := eh.getEntry(id)
 	if !exists {
 		http.Error(w, "Entry not found", http.StatusNotFound)
 		return
 	}
 
-	if entry.UserID != userID || entry.TenantID != tenantID {
+	if !canAccessEntry(principal, entry) {
 		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
@@ -276,20 +564,52 @@ func (eh *EmailHandler) GetEmailTracking(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(entry)
 }
 
+// GetEmailTrackingEvents returns the entry's timeline, oldest first.
+func (eh *EmailHandler) GetEmailTrackingEvents(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+
+	entry, exists := eh.getEntry(id)
+	if !exists {
+		http.Error(w, "Entry not found", http.StatusNotFound)
+		return
+	}
+
+	if !canAccessEntry(principal, entry) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	timeline := eh.events.List(id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events": timeline,
+		"count":  len(timeline),
+	})
+}
+
 func (eh *EmailHandler) UpdateEmailTracking(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
-	tenantID := r.Context().Value("tenantID").(string)
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
 
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	entry, exists := eh.trackingStore[id]
+	entry, exists := eh.getEntry(id)
 	if !exists {
 		http.Error(w, "Entry not found", http.StatusNotFound)
 		return
 	}
 
-	if entry.UserID != userID || entry.TenantID != tenantID {
+	if !canAccessEntry(principal, entry) {
 		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
@@ -300,19 +620,31 @@ func (eh *EmailHandler) UpdateEmailTracking(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Update the entry
-	if req.Status != "" {
-		entry.Status = req.Status
-	}
-	if req.TemporalWorkflow != "" {
-		entry.TemporalWorkflow = req.TemporalWorkflow
-	}
-	if req.Metadata != nil {
-		entry.Metadata = req.Metadata
+	// Update the entry as stored now, not the copy read above, so changes
+	// made meanwhile by tracking or the workflow monitor are kept
+	var previousStatus string
+	entry, exists = eh.updateEntry(id, func(entry *EmailTrackingEntry) bool {
+		previousStatus = entry.Status
+		if req.Status != "" {
+			entry.Status = req.Status
+		}
+		if req.TemporalWorkflow != "" {
+			entry.TemporalWorkflow = req.TemporalWorkflow
+		}
+		if req.Metadata != nil {
+			entry.Metadata = req.Metadata
+		}
+		entry.Timestamp = time.Now().UTC()
+		return true
+	})
+	if !exists {
+		http.Error(w, "Entry not found", http.StatusNotFound)
+		return
 	}
-	entry.Timestamp = time.Now().UTC()
-
-	eh.trackingStore[id] = entry
+	eh.events.Append(id, events.TypeUpdated, events.UserActor(userID), map[string]interface{}{
+		"status":         entry.Status,
+		"previousStatus": previousStatus,
+	})
 
 	eh.logger.Info("Updated email tracking entry", "entry_id", id, "status", entry.Status)
 
@@ -321,24 +653,27 @@ func (eh *EmailHandler) UpdateEmailTracking(w http.ResponseWriter, r *http.Reque
 }
 
 func (eh *EmailHandler) DeleteEmailTracking(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(string)
-	tenantID := r.Context().Value("tenantID").(string)
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
 
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	entry, exists := eh.trackingStore[id]
+	entry, exists := eh.getEntry(id)
 	if !exists {
 		http.Error(w, "Entry not found", http.StatusNotFound)
 		return
 	}
 
-	if entry.UserID != userID || entry.TenantID != tenantID {
+	if !canAccessEntry(principal, entry) {
 		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
 
-	delete(eh.trackingStore, id)
+	eh.deleteEntry(id)
+	eh.events.Remove(id)
 
 	eh.logger.Info("Deleted email tracking entry", "entry_id", id)
 
@@ -353,15 +688,11 @@ func (eh *EmailHandler) startEmailWorkflow(entry EmailTrackingEntry) {
 	defer cancel()
 
 	// Convert to activity data format
-	emailData := activities.EmailData{
-		ID:        entry.ID,
-		UserID:    entry.UserID,
-		TenantID:  entry.TenantID,
-		EmailID:   entry.EmailID,
-		Status:    entry.Status,
-		Timestamp: entry.Timestamp,
-		Workflow:  entry.TemporalWorkflow,
-		Metadata:  entry.Metadata,
+	emailData, err := eh.emailDataFor(entry)
+	if err != nil {
+		logger.Error("Failed to prepare email data", "error", err)
+		eh.markWorkflowFailed(entry.ID, err)
+		return
 	}
 
 	workflowID := fmt.Sprintf("email-workflow-%s", entry.EmailID)
@@ -369,13 +700,7 @@ func (eh *EmailHandler) startEmailWorkflow(entry EmailTrackingEntry) {
 	workflowRun, err := eh.temporalClient.StartEmailWorkflow(ctx, workflowID, eh.taskQueue, emailData)
 	if err != nil {
 		logger.Error("Failed to start email workflow", "error", err)
-		// Update entry status to failed
-		entry.Status = "workflow_failed"
-		if entry.Metadata == nil {
-			entry.Metadata = make(map[string]interface{})
-		}
-		entry.Metadata["error"] = err.Error()
-		eh.trackingStore[entry.ID] = entry
+		eh.markWorkflowFailed(entry.ID, err)
 		return
 	}
 
@@ -383,17 +708,13 @@ func (eh *EmailHandler) startEmailWorkflow(entry EmailTrackingEntry) {
 		"workflow_id", workflowRun.GetID(),
 		"run_id", workflowRun.GetRunID())
 
-	// Update entry with workflow information
-	entry.Status = "workflow_started"
-	if entry.Metadata == nil {
-		entry.Metadata = make(map[string]interface{})
+	entry, ok := eh.markWorkflowStarted(entry.ID, workflowRun, "workflow_started", "started")
+	if !ok {
+		return
 	}
-	entry.Metadata["workflowRunId"] = workflowRun.GetRunID()
-	entry.Metadata["workflowStatus"] = "started"
-	eh.trackingStore[entry.ID] = entry
 
 	// Monitor workflow completion
-	go eh.monitorWorkflow(workflowRun, entry)
+	go eh.monitorWorkflow(workflowRun, "EmailWorkflow", entry)
 }
 
 func (eh *EmailHandler) scheduleEmailWorkflow(entry EmailTrackingEntry) {
@@ -422,15 +743,11 @@ func (eh *EmailHandler) scheduleEmailWorkflow(entry EmailTrackingEntry) {
 	defer cancel()
 
 	// Convert to activity data format
-	emailData := activities.EmailData{
-		ID:        entry.ID,
-		UserID:    entry.UserID,
-		TenantID:  entry.TenantID,
-		EmailID:   entry.EmailID,
-		Status:    entry.Status,
-		Timestamp: entry.Timestamp,
-		Workflow:  entry.TemporalWorkflow,
-		Metadata:  entry.Metadata,
+	emailData, err := eh.emailDataFor(entry)
+	if err != nil {
+		logger.Error("Failed to prepare email data", "error", err)
+		eh.markWorkflowFailed(entry.ID, err)
+		return
 	}
 
 	workflowID := fmt.Sprintf("scheduled-email-workflow-%s", entry.EmailID)
@@ -438,13 +755,7 @@ func (eh *EmailHandler) scheduleEmailWorkflow(entry EmailTrackingEntry) {
 	workflowRun, err := eh.temporalClient.StartScheduledEmailWorkflow(ctx, workflowID, eh.taskQueue, *entry.ScheduledAt, emailData)
 	if err != nil {
 		logger.Error("Failed to start scheduled email workflow", "error", err)
-		// Update entry status to failed
-		entry.Status = "workflow_failed"
-		if entry.Metadata == nil {
-			entry.Metadata = make(map[string]interface{})
-		}
-		entry.Metadata["error"] = err.Error()
-		eh.trackingStore[entry.ID] = entry
+		eh.markWorkflowFailed(entry.ID, err)
 		return
 	}
 
@@ -453,17 +764,13 @@ func (eh *EmailHandler) scheduleEmailWorkflow(entry EmailTrackingEntry) {
 		"run_id", workflowRun.GetRunID(),
 		"scheduled_at", entry.ScheduledAt)
 
-	// Update entry with workflow information
-	entry.Status = "workflow_scheduled"
-	if entry.Metadata == nil {
-		entry.Metadata = make(map[string]interface{})
+	entry, ok := eh.markWorkflowStarted(entry.ID, workflowRun, "workflow_scheduled", "scheduled")
+	if !ok {
+		return
 	}
-	entry.Metadata["workflowRunId"] = workflowRun.GetRunID()
-	entry.Metadata["workflowStatus"] = "scheduled"
-	eh.trackingStore[entry.ID] = entry
 
 	// Monitor workflow completion
-	go eh.monitorWorkflow(workflowRun, entry)
+	go eh.monitorWorkflow(workflowRun, "ScheduledEmailWorkflow", entry)
 }
 
 func (eh *EmailHandler) startReviewerApprovalWorkflow(entry EmailTrackingEntry) {
@@ -473,28 +780,19 @@ func (eh *EmailHandler) startReviewerApprovalWorkflow(entry EmailTrackingEntry)
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	emailData := activities.EmailData{
-		ID:        entry.ID,
-		UserID:    entry.UserID,
-		TenantID:  entry.TenantID,
-		EmailID:   entry.EmailID,
-		Status:    entry.Status,
-		Timestamp: entry.Timestamp,
-		Workflow:  entry.TemporalWorkflow,
-		Metadata:  entry.Metadata,
+	emailData, err := eh.emailDataFor(entry)
+	if err != nil {
+		logger.Error("Failed to prepare email data", "error", err)
+		eh.markWorkflowFailed(entry.ID, err)
+		return
 	}
 
-	workflowID := fmt.Sprintf("reviewer-email-workflow-%s", entry.EmailID)
+	workflowID := approval.WorkflowID(entry.EmailID)
 
 	workflowRun, err := eh.temporalClient.StartReviewerApprovalEmailWorkflow(ctx, workflowID, eh.taskQueue, emailData)
 	if err != nil {
 		logger.Error("Failed to start reviewer approval workflow", "error", err)
-		entry.Status = "workflow_failed"
-		if entry.Metadata == nil {
-			entry.Metadata = make(map[string]interface{})
-		}
-		entry.Metadata["error"] = err.Error()
-		eh.trackingStore[entry.ID] = entry
+		eh.markWorkflowFailed(entry.ID, err)
 		return
 	}
 
@@ -502,97 +800,183 @@ func (eh *EmailHandler) startReviewerApprovalWorkflow(entry EmailTrackingEntry)
 		"workflow_id", workflowRun.GetID(),
 		"run_id", workflowRun.GetRunID())
 
-	entry.Status = "awaiting_approval"
-	if entry.Metadata == nil {
-		entry.Metadata = make(map[string]interface{})
+	entry, ok := eh.markWorkflowStarted(entry.ID, workflowRun, "awaiting_approval", "awaiting_approval")
+	if !ok {
+		return
 	}
-	entry.Metadata["workflowRunId"] = workflowRun.GetRunID()
-	entry.Metadata["workflowStatus"] = "awaiting_approval"
-	eh.trackingStore[entry.ID] = entry
 
-	go eh.monitorWorkflow(workflowRun, entry)
+	go eh.monitorWorkflow(workflowRun, "ReviewerApprovalEmailWorkflow", entry)
+}
+
+// markWorkflowFailed records on the entry that its workflow could not be
+// started. An entry deleted meanwhile stays gone.
+func (eh *EmailHandler) markWorkflowFailed(entryID string, err error) {
+	_, ok := eh.updateEntry(entryID, func(entry *EmailTrackingEntry) bool {
+		entry.Status = "workflow_failed"
+		if entry.Metadata == nil {
+			entry.Metadata = make(map[string]interface{})
+		}
+		entry.Metadata["error"] = err.Error()
+		return true
+	})
+	if ok {
+		eh.events.Append(entryID, events.TypeWorkflowFailed, events.ActorSystem, map[string]interface{}{"error": err.Error()})
+	}
 }
 
-// generateTokenSignature creates a unique signature for a token to track its usage
-func (eh *EmailHandler) generateTokenSignature(tokenString string) string {
-	// Use SHA256 hash of the token to create a signature - this allows us to track
-	// the exact token that was used without storing the full token
-	hash := sha256.Sum256([]byte(tokenString))
-	return hex.EncodeToString(hash[:])[:32] // Use first 32 chars of hex hash
+// markWorkflowStarted records a started workflow run on the stored entry,
+// changing only the status and workflow fields so anything recorded while
+// the workflow was starting is kept. If the entry was deleted or erased in
+// the meantime the run is cancelled instead and false is returned.
+func (eh *EmailHandler) markWorkflowStarted(entryID string, workflowRun temporalclient.WorkflowRun, status, workflowStatus string) (EmailTrackingEntry, bool) {
+	entry, ok := eh.updateEntry(entryID, func(entry *EmailTrackingEntry) bool {
+		entry.Status = status
+		if entry.Metadata == nil {
+			entry.Metadata = make(map[string]interface{})
+		}
+		entry.Metadata["workflowId"] = workflowRun.GetID()
+		entry.Metadata["workflowRunId"] = workflowRun.GetRunID()
+		entry.Metadata["workflowStatus"] = workflowStatus
+		return true
+	})
+	if !ok {
+		eh.logger.Info("Tracking entry was removed while its workflow started; cancelling", "entry_id", entryID, "workflow_id", workflowRun.GetID())
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := eh.temporalClient.CancelWorkflow(ctx, workflowRun.GetID(), workflowRun.GetRunID()); err != nil {
+			eh.logger.Warn("Failed to cancel workflow of removed entry", "error", err, "entry_id", entryID)
+		}
+		return EmailTrackingEntry{}, false
+	}
+	eh.events.Append(entryID, events.TypeWorkflowStarted, events.ActorSystem, map[string]interface{}{
+		"workflowId": workflowRun.GetID(),
+		"runId":      workflowRun.GetRunID(),
+	})
+	return entry, true
 }
 
 // cleanupExpiredTokens removes tokens that are older than the specified duration
 // This prevents the usedTokens map from growing indefinitely
 func (eh *EmailHandler) cleanupExpiredTokens(maxAge time.Duration) {
+	eh.tokensMu.Lock()
+	defer eh.tokensMu.Unlock()
 	cutoff := time.Now().UTC().Add(-maxAge)
-	for signature, usedAt := range eh.usedTokens {
+	for jti, usedAt := range eh.usedTokens {
 		if usedAt.Before(cutoff) {
-			delete(eh.usedTokens, signature)
+			delete(eh.usedTokens, jti)
 		}
 	}
 	eh.logger.Info("Cleaned up expired tokens", "cutoff", cutoff, "remaining_tokens", len(eh.usedTokens))
 }
 
-// ApproveEmail handles approval link clicks from reviewers and signals the workflow to proceed
+// claimToken marks an approval token as used and reports whether it was
+// unused, checking and marking under one lock so concurrent requests with
+// the same link cannot both get through. It also returns when the token was
+// first used.
+func (eh *EmailHandler) claimToken(jti string, at time.Time) (time.Time, bool) {
+	eh.tokensMu.Lock()
+	defer eh.tokensMu.Unlock()
+	if usedAt, exists := eh.usedTokens[jti]; exists {
+		return usedAt, false
+	}
+	eh.usedTokens[jti] = at
+	return at, true
+}
+
+// tokenUsedAt reports when an approval token was used, if it was.
+func (eh *EmailHandler) tokenUsedAt(jti string) (time.Time, bool) {
+	eh.tokensMu.Lock()
+	defer eh.tokensMu.Unlock()
+	usedAt, exists := eh.usedTokens[jti]
+	return usedAt, exists
+}
+
+// releaseToken makes a claimed token usable again after its decision could
+// not be delivered.
+func (eh *EmailHandler) releaseToken(jti string) {
+	eh.tokensMu.Lock()
+	defer eh.tokensMu.Unlock()
+	delete(eh.usedTokens, jti)
+}
+
+// ApproveEmail handles approve and reject links sent to reviewers. GET shows
+// a confirmation page so link scanners that prefetch URLs cannot act on
+// anything; POST from that page signals the workflow and records which
+// reviewer decided.
 func (eh *EmailHandler) ApproveEmail(w http.ResponseWriter, r *http.Request) {
 	logger := eh.logger.WithContext(r.Context())
 
-	// Token contains emailId and workflowId; signed with JWT secret shared with Node backend
-	tokenString := r.URL.Query().Get("token")
+	// Token names the email, the action and the reviewer it was issued to
+	tokenString := r.FormValue("token")
 	if tokenString == "" {
 		logger.Warn("Approval request missing token")
 		http.Error(w, "token is required", http.StatusBadRequest)
 		return
 	}
 
-	// Generate a signature for this token to track usage
-	tokenSignature := eh.generateTokenSignature(tokenString)
+	claims, err := eh.approvals.Verify(tokenString)
+	if err != nil {
+		logger.Warn("Invalid approval token", "error", err)
+		http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+		return
+	}
 
 	// Check if this token has already been used
-	if usedAt, exists := eh.usedTokens[tokenSignature]; exists {
-		logger.Warn("Attempt to reuse approval token",
-			"token_signature", tokenSignature,
-			"originally_used_at", usedAt)
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.WriteHeader(http.StatusConflict)
-		fmt.Fprintf(w, "<html><body><h3>Token Already Used</h3><p>This approval link has already been used and cannot be used again for security reasons. If you need to approve this email again, please request a new approval link.</p></body></html>")
+	if usedAt, exists := eh.tokenUsedAt(claims.ID); exists {
+		writeTokenUsed(w, logger, claims.ID, usedAt)
 		return
 	}
 
-	type ApprovalClaims struct {
-		EmailID    string `json:"emailId"`
-		WorkflowID string `json:"workflowId"`
-		jwt.RegisteredClaims
+	// The link must have been issued to the reviewer recorded on the email.
+	// Without the entry there is nothing to check the reviewer against, so
+	// the link is refused.
+	entryID, entry, found := eh.entryForEmailID(claims.EmailID)
+	if !found {
+		logger.Warn("Approval token for unknown email", "email_id", claims.EmailID, "jti", claims.ID)
+		http.Error(w, "email not found", http.StatusNotFound)
+		return
 	}
-
-	token, err := jwt.ParseWithClaims(tokenString, &ApprovalClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(eh.jwtSecret), nil
-	})
-	if err != nil || !token.Valid {
-		logger.Error("Invalid approval token", "error", err, "token_signature", tokenSignature)
-		http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+	if reviewer, _ := entry.Metadata["reviewerEmail"].(string); !strings.EqualFold(strings.TrimSpace(reviewer), claims.Subject) {
+		logger.Warn("Approval token issued to a different reviewer", "email_id", claims.EmailID, "jti", claims.ID)
+		http.Error(w, "this approval link was issued to a different reviewer", http.StatusForbidden)
 		return
 	}
 
-	claims, ok := token.Claims.(*ApprovalClaims)
-	if !ok {
-		logger.Error("Invalid token claims", "token_signature", tokenSignature)
-		http.Error(w, "invalid token claims", http.StatusUnauthorized)
+	rejecting := claims.Action == approval.ActionReject
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodGet {
+		subject, _ := entry.Metadata["subject"].(string)
+		if subject == "" {
+			subject = "Email campaign"
+		}
+		heading, button := "Approve email", "Approve and send"
+		if rejecting {
+			heading, button = "Reject email", "Reject"
+		}
+		fmt.Fprintf(w, `<html><body><h3>%s</h3><p><strong>%s</strong> is waiting for your review.</p><p>Reviewing as <strong>%s</strong>.</p><form method="POST" action="/approve-email"><input type="hidden" name="token" value="%s"><button type="submit">%s</button></form></body></html>`,
+			heading, html.EscapeString(subject), html.EscapeString(claims.Subject), html.EscapeString(tokenString), button)
 		return
 	}
 
 	logger.Info("Processing approval request",
 		"email_id", claims.EmailID,
 		"workflow_id", claims.WorkflowID,
-		"token_signature", tokenSignature)
+		"action", claims.Action,
+		"jti", claims.ID)
+
+	// Claim the token before signalling so a concurrent request with the
+	// same link is refused; the claim is released if the signal fails
+	decidedAt := time.Now().UTC()
+	if usedAt, claimed := eh.claimToken(claims.ID, decidedAt); !claimed {
+		writeTokenUsed(w, logger, claims.ID, usedAt)
+		return
+	}
 
-	// Signal the workflow to approve
+	// Signal the workflow with the reviewer's decision
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
-	if err := eh.temporalClient.SignalApproval(ctx, claims.WorkflowID, "", "approve"); err != nil {
+	if err := eh.temporalClient.SignalApproval(ctx, claims.WorkflowID, "", claims.Action); err != nil {
+		eh.releaseToken(claims.ID)
 		logger.Error("Failed to signal workflow approval",
 			"error", err,
 			"workflow_id", claims.WorkflowID,
@@ -601,74 +985,199 @@ func (eh *EmailHandler) ApproveEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Mark this token as used AFTER successful approval to prevent reuse
-	eh.usedTokens[tokenSignature] = time.Now().UTC()
+	if rejecting {
+		metrics.Approval(metrics.ApprovalRejected)
+	} else {
+		metrics.Approval(metrics.ApprovalGranted)
+	}
+
 	logger.Info("Token marked as used",
-		"token_signature", tokenSignature,
+		"jti", claims.ID,
 		"email_id", claims.EmailID)
 
-	// Update tracking entry status if we can find it
-	for id, entry := range eh.trackingStore {
-		if entry.EmailID == claims.EmailID {
-			entry.Status = "approved"
-			entry.Timestamp = time.Now().UTC()
-			if entry.Metadata == nil {
-				entry.Metadata = make(map[string]interface{})
-			}
-			entry.Metadata["workflowStatus"] = "approved"
-			entry.Metadata["approvalTokenUsed"] = tokenSignature
-			eh.trackingStore[id] = entry
-			break
+	status, eventType, prefix := "approved", events.TypeApproved, "approved"
+	if rejecting {
+		status, eventType, prefix = "rejected", events.TypeRejected, "rejected"
+	}
+	eh.updateEntry(entryID, func(entry *EmailTrackingEntry) bool {
+		entry.Status = status
+		entry.Timestamp = decidedAt
+		if entry.Metadata == nil {
+			entry.Metadata = make(map[string]interface{})
 		}
+		entry.Metadata["workflowStatus"] = status
+		entry.Metadata["approvalTokenUsed"] = claims.ID
+		entry.Metadata[prefix+"By"] = claims.Subject
+		entry.Metadata[prefix+"At"] = decidedAt.Format(time.RFC3339)
+		if claims.ReviewerID != "" {
+			entry.Metadata[prefix+"ByReviewerId"] = claims.ReviewerID
+		}
+		return true
+	})
+	details := map[string]interface{}{
+		"workflowId": claims.WorkflowID,
+		"reviewer":   claims.Subject,
+	}
+	if claims.ReviewerID != "" {
+		details["reviewerId"] = claims.ReviewerID
 	}
+	eh.events.Append(entryID, eventType, events.ActorReviewer, details)
 
-	logger.Info("Email approval completed successfully",
+	logger.Info("Email review completed successfully",
 		"email_id", claims.EmailID,
-		"workflow_id", claims.WorkflowID)
+		"workflow_id", claims.WorkflowID,
+		"action", claims.Action)
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
+	if rejecting {
+		fmt.Fprintf(w, "<html><body><h3>Email rejected</h3><p>The email will not be sent.</p></body></html>")
+		return
+	}
 	fmt.Fprintf(w, "<html><body><h3>Approval received</h3><p>The email has been approved and will be sent shortly.</p></body></html>")
 }
 
-func (eh *EmailHandler) monitorWorkflow(workflowRun temporalclient.WorkflowRun, entry EmailTrackingEntry) {
+// writeTokenUsed answers a request with an approval link that was already used.
+func writeTokenUsed(w http.ResponseWriter, log *logger.Logger, jti string, usedAt time.Time) {
+	log.Warn("Attempt to reuse approval token",
+		"jti", jti,
+		"originally_used_at", usedAt)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusConflict)
+	fmt.Fprintf(w, "<html><body><h3>Token Already Used</h3><p>This approval link has already been used and cannot be used again for security reasons. If you need to approve this email again, please request a new approval link.</p></body></html>")
+}
+
+// entryForEmailID finds the tracking entry created for an emailId.
+func (eh *EmailHandler) entryForEmailID(emailID string) (string, EmailTrackingEntry, bool) {
+	eh.mu.RLock()
+	defer eh.mu.RUnlock()
+	for id, entry := range eh.trackingStore {
+		if entry.EmailID == emailID {
+			return id, entry.clone(), true
+		}
+	}
+	return "", EmailTrackingEntry{}, false
+}
+
+func (eh *EmailHandler) monitorWorkflow(workflowRun temporalclient.WorkflowRun, workflowType string, entry EmailTrackingEntry) {
 	logger := eh.logger.WithEmail(entry.EmailID).WithWorkflow(workflowRun.GetID())
 	logger.Info("Monitoring workflow completion")
 
+	metrics.WorkflowStarted(workflowType)
 	var result activities.SendEmailResult
 	err := workflowRun.Get(context.Background(), &result)
+	metrics.WorkflowFinished(workflowType)
+	if err == nil && result.Status == events.TypeApprovalTimeout {
+		metrics.Approval(metrics.ApprovalTimedOut)
+	}
 
-	// Update the entry with final status
 	if err != nil {
 		logger.Error("Workflow failed", "error", err)
-		entry.Status = "failed"
-		if entry.Metadata == nil {
-			entry.Metadata = make(map[string]interface{})
-		}
-		entry.Metadata["workflowError"] = err.Error()
-		entry.Metadata["workflowStatus"] = "failed"
 	} else {
 		logger.Info("Workflow completed successfully", "status", result.Status, "resend_id", result.ResendID)
-		// Respect workflow result status (e.g., sent, approval_timeout)
-		if result.Status != "" {
-			entry.Status = result.Status
-		} else {
-			entry.Status = "sent"
-		}
+	}
+
+	// Update the stored entry so opens and clicks recorded while the workflow
+	// ran are kept. An entry deleted or erased meanwhile stays gone.
+	entry, ok := eh.updateEntry(entry.ID, func(entry *EmailTrackingEntry) bool {
 		if entry.Metadata == nil {
 			entry.Metadata = make(map[string]interface{})
 		}
-		entry.Metadata["workflowResult"] = result
-		entry.Metadata["workflowStatus"] = "completed"
-		entry.Metadata["resendId"] = result.ResendID
+		if err != nil {
+			entry.Status = "failed"
+			entry.Metadata["workflowError"] = err.Error()
+			entry.Metadata["workflowStatus"] = "failed"
+		} else {
+			// Respect workflow result status (e.g., sent, approval_timeout)
+			if result.Status != "" {
+				entry.Status = result.Status
+			} else {
+				entry.Status = "sent"
+			}
+			entry.Metadata["workflowResult"] = result
+			entry.Metadata["workflowStatus"] = "completed"
+			entry.Metadata["resendId"] = result.ResendID
+		}
+		entry.Timestamp = time.Now().UTC()
+		return true
+	})
+	if !ok {
+		logger.Info("Tracking entry was removed while the workflow ran; not recording result")
+		return
 	}
 
-	entry.Timestamp = time.Now().UTC()
-	eh.trackingStore[entry.ID] = entry
+	if err != nil {
+		eh.events.Append(entry.ID, events.TypeFailed, events.ActorWorkflow, map[string]interface{}{"error": err.Error()})
+	} else {
+		details := map[string]interface{}{}
+		if result.ResendID != "" {
+			details["resendId"] = result.ResendID
+		}
+		if result.Error != "" {
+			details["error"] = result.Error
+		}
+		// A rejection was already recorded with the reviewer by ApproveEmail
+		if entry.Status != events.TypeRejected {
+			eh.events.Append(entry.ID, entry.Status, events.ActorWorkflow, details)
+		}
+	}
 
 	logger.Info("Workflow monitoring completed", "final_status", entry.Status)
 }
 
+// emailDataFor converts a tracking entry into workflow input, attaching the
+// pinned template version so the worker renders exactly that revision and the
+// tenant's verified sender address.
+func (eh *EmailHandler) emailDataFor(entry EmailTrackingEntry) (activities.EmailData, error) {
+	emailData := activities.EmailData{
+		ID:          entry.ID,
+		UserID:      entry.UserID,
+		TenantID:    entry.TenantID,
+		EmailID:     entry.EmailID,
+		Status:      entry.Status,
+		Timestamp:   entry.Timestamp,
+		Workflow:    entry.TemporalWorkflow,
+		Test:        entry.Test,
+		Metadata:    entry.Metadata,
+		Cc:          entry.Cc,
+		Bcc:         entry.Bcc,
+		ReplyTo:     entry.ReplyTo,
+		Headers:     entry.Headers,
+		Attachments: entry.Attachments,
+		// One setting bounds both the reviewer's links and the wait for them
+		ApprovalTimeout: eh.approvals.TTL(),
+	}
+
+	if entry.TemplateID != "" {
+		_, version, err := eh.templates.Resolve(entry.TenantID, entry.TemplateID, entry.TemplateVersion)
+		if err != nil {
+			return activities.EmailData{}, fmt.Errorf("failed to resolve template %s v%d: %w", entry.TemplateID, entry.TemplateVersion, err)
+		}
+		emailData.Template = &version
+	}
+
+	// The identity is resolved at send time so a sender deleted or replaced
+	// as default in the meantime is not used
+	identity, ok, err := eh.senders.Resolve(entry.TenantID, entry.SenderIdentityID)
+	if err != nil {
+		return activities.EmailData{}, fmt.Errorf("failed to resolve sender identity %s: %w", entry.SenderIdentityID, err)
+	}
+	if ok {
+		emailData.From = identity.Address()
+	}
+
+	return emailData, nil
+}
+
+// writeViolations rejects content that fails the sanitizer policy, listing
+// each violation so the client can show what needs to change.
+func writeViolations(w http.ResponseWriter, violations []emailhtml.Violation) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":      "content contains HTML that is not allowed",
+		"violations": violations,
+	})
+}
+
 func generateID() string {
 	return fmt.Sprintf("%d", time.Now().UnixNano())
 }
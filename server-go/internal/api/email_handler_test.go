@@ -0,0 +1,49 @@
+package api
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClaimToken(t *testing.T) {
+	eh := &EmailHandler{usedTokens: make(map[string]time.Time)}
+
+	if _, ok := eh.claimToken("jti-1", time.Now()); !ok {
+		t.Fatal("first claim refused")
+	}
+	if _, ok := eh.claimToken("jti-1", time.Now()); ok {
+		t.Fatal("reused token claimed again")
+	}
+	if _, ok := eh.claimToken("jti-2", time.Now()); !ok {
+		t.Fatal("other token refused")
+	}
+
+	eh.releaseToken("jti-1")
+	if _, ok := eh.claimToken("jti-1", time.Now()); !ok {
+		t.Fatal("released token refused")
+	}
+}
+
+// TestClaimTokenConcurrent checks only one of many simultaneous requests with
+// the same link gets through.
+func TestClaimTokenConcurrent(t *testing.T) {
+	eh := &EmailHandler{usedTokens: make(map[string]time.Time)}
+
+	var claimed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := eh.claimToken("jti-1", time.Now()); ok {
+				claimed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := claimed.Load(); n != 1 {
+		t.Errorf("claimed %d times, want 1", n)
+	}
+}
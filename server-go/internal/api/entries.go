@@ -0,0 +1,88 @@
+package api
+
+import "email-tracking-server/internal/activities"
+
+// Tracking entries are read and written by API handlers, public tracking and
+// webhook endpoints and workflow monitors concurrently. All access goes
+// through these helpers, which hold eh.mu and hand out copies so callers can
+// change an entry's metadata without touching the stored map.
+
+// getEntry returns a copy of the entry with the given ID.
+func (eh *EmailHandler) getEntry(id string) (EmailTrackingEntry, bool) {
+	eh.mu.RLock()
+	defer eh.mu.RUnlock()
+	entry, ok := eh.trackingStore[id]
+	if !ok {
+		return EmailTrackingEntry{}, false
+	}
+	return entry.clone(), true
+}
+
+// putEntry stores a new entry. Changes to an existing entry go through
+// updateEntry so they apply to the stored copy rather than a stale one.
+func (eh *EmailHandler) putEntry(entry EmailTrackingEntry) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	eh.trackingStore[entry.ID] = entry.clone()
+}
+
+// deleteEntry removes the entry with the given ID.
+func (eh *EmailHandler) deleteEntry(id string) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	delete(eh.trackingStore, id)
+}
+
+// updateEntry applies fn to a copy of the stored entry and saves the result
+// if fn returns true, all under the lock so concurrent updates are not lost.
+// It returns the entry as stored afterwards and whether it exists.
+func (eh *EmailHandler) updateEntry(id string, fn func(*EmailTrackingEntry) bool) (EmailTrackingEntry, bool) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	stored, ok := eh.trackingStore[id]
+	if !ok {
+		return EmailTrackingEntry{}, false
+	}
+	entry := stored.clone()
+	if !fn(&entry) {
+		return stored.clone(), true
+	}
+	eh.trackingStore[id] = entry.clone()
+	return entry, true
+}
+
+// findEntries returns copies of the entries that match.
+func (eh *EmailHandler) findEntries(match func(EmailTrackingEntry) bool) []EmailTrackingEntry {
+	eh.mu.RLock()
+	defer eh.mu.RUnlock()
+	var found []EmailTrackingEntry
+	for _, entry := range eh.trackingStore {
+		if match(entry) {
+			found = append(found, entry.clone())
+		}
+	}
+	return found
+}
+
+// clone copies the entry's metadata, headers and address lists so the copy
+// can be changed independently.
+func (e EmailTrackingEntry) clone() EmailTrackingEntry {
+	if e.Metadata != nil {
+		metadata := make(map[string]interface{}, len(e.Metadata))
+		for k, v := range e.Metadata {
+			metadata[k] = v
+		}
+		e.Metadata = metadata
+	}
+	if e.Headers != nil {
+		headers := make(map[string]string, len(e.Headers))
+		for k, v := range e.Headers {
+			headers[k] = v
+		}
+		e.Headers = headers
+	}
+	e.Cc = append([]string(nil), e.Cc...)
+	e.Bcc = append([]string(nil), e.Bcc...)
+	e.Attachments = append([]activities.Attachment(nil), e.Attachments...)
+	return e
+}
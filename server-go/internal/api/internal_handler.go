@@ -0,0 +1,68 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"email-tracking-server/internal/events"
+	"email-tracking-server/internal/serverapi"
+
+	"github.com/gorilla/mux"
+)
+
+// workflowEventTypes are the timeline events workflows may report; outcomes
+// such as sent or failed are recorded by the server when the workflow ends.
+var workflowEventTypes = map[string]bool{
+	events.TypeSending:          true,
+	events.TypeReviewerNotified: true,
+}
+
+// InternalAuth protects worker-to-server endpoints with the shared internal
+// token. With no token configured the endpoints are disabled.
+func InternalAuth(token string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				http.NotFound(w, r)
+				return
+			}
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get(serverapi.TokenHeader)), []byte(token)) != 1 {
+				http.Error(w, "Invalid internal token", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type WorkflowEventRequest struct {
+	Type    string                 `json:"type"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// RecordWorkflowEvent appends an event reported by a workflow to an entry's
+// timeline.
+func (eh *EmailHandler) RecordWorkflowEvent(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req WorkflowEventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if !workflowEventTypes[req.Type] {
+		http.Error(w, "unsupported event type", http.StatusBadRequest)
+		return
+	}
+	if _, exists := eh.getEntry(id); !exists {
+		http.Error(w, "Entry not found", http.StatusNotFound)
+		return
+	}
+
+	event := eh.events.Append(id, req.Type, events.ActorWorkflow, req.Details)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(event)
+}
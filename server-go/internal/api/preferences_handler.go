@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"email-tracking-server/internal/events"
+	"email-tracking-server/internal/preferences"
+	"email-tracking-server/internal/tracking"
+	"email-tracking-server/pkg/logger"
+)
+
+type PreferencesHandler struct {
+	emails    *EmailHandler
+	store     *preferences.Store
+	events    *events.Log
+	jwtSecret string
+	logger    *logger.Logger
+}
+
+type PreferenceCheckRequest struct {
+	TenantID string `json:"tenantId"`
+	Email    string `json:"email"`
+	Category string `json:"category"`
+}
+
+func NewPreferencesHandler(emails *EmailHandler, store *preferences.Store, eventLog *events.Log, jwtSecret string, log *logger.Logger) *PreferencesHandler {
+	return &PreferencesHandler{
+		emails:    emails,
+		store:     store,
+		events:    eventLog,
+		jwtSecret: jwtSecret,
+		logger:    log,
+	}
+}
+
+// Preferences serves the preference center linked from bulk emails. GET shows
+// the recipient's categories as checkboxes; POST from that form saves them.
+// Unchecked categories are opted out.
+func (ph *PreferencesHandler) Preferences(w http.ResponseWriter, r *http.Request) {
+	logger := ph.logger.WithContext(r.Context())
+
+	tokenString := r.FormValue("token")
+	if tokenString == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := tracking.Parse(ph.jwtSecret, tracking.PurposePreferences, tokenString)
+	if err != nil || claims.TenantID == "" || claims.Recipient == "" {
+		logger.Warn("Invalid preferences token", "error", err)
+		http.Error(w, "invalid preferences link", http.StatusBadRequest)
+		return
+	}
+
+	saved := false
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid form", http.StatusBadRequest)
+			return
+		}
+		subscribed := map[string]bool{}
+		for _, category := range r.PostForm["category"] {
+			subscribed[category] = true
+		}
+		optedOut := map[string]bool{}
+		for _, category := range preferences.Categories {
+			optedOut[category] = !subscribed[category]
+		}
+
+		prefs := ph.store.Set(claims.TenantID, claims.Recipient, optedOut)
+		// Only recorded while the entry exists, as for unsubscribes
+		if _, ok := ph.emails.getEntry(claims.EntryID); ok {
+			ph.events.Append(claims.EntryID, events.TypePreferences, events.ActorRecipient, map[string]interface{}{
+				"recipient": claims.Recipient,
+				"optedOut":  prefs.OptedOut,
+			})
+		}
+		logger.Info("Recipient updated preferences", "entry_id", claims.EntryID, "tenant_id", claims.TenantID)
+		saved = true
+	}
+
+	prefs := ph.store.Get(claims.TenantID, claims.Recipient)
+
+	var options strings.Builder
+	for _, category := range preferences.Categories {
+		checked := ""
+		if !prefs.OptedOut[category] {
+			checked = " checked"
+		}
+		fmt.Fprintf(&options, `<p><label><input type="checkbox" name="category" value="%s"%s> %s</label></p>`,
+			html.EscapeString(category), checked, html.EscapeString(preferences.CategoryLabels[category]))
+	}
+	notice := ""
+	if saved {
+		notice = "<p><em>Your preferences have been saved.</em></p>"
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<html><body><h3>Email preferences</h3>%s<p>Choose which emails <strong>%s</strong> receives.</p><form method="POST" action="/preferences"><input type="hidden" name="token" value="%s">%s<button type="submit">Save preferences</button></form></body></html>`,
+		notice, html.EscapeString(claims.Recipient), html.EscapeString(tokenString), options.String())
+}
+
+// CheckPreference reports whether a contact opted out of a category. It backs
+// the check in the worker's SendEmail activity.
+func (ph *PreferencesHandler) CheckPreference(w http.ResponseWriter, r *http.Request) {
+	var req PreferenceCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"optedOut": ph.store.OptedOut(req.TenantID, req.Email, req.Category),
+	})
+}
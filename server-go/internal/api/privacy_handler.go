@@ -0,0 +1,377 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"email-tracking-server/internal/audit"
+	"email-tracking-server/internal/blobstore"
+	"email-tracking-server/internal/events"
+	"email-tracking-server/internal/preferences"
+	"email-tracking-server/internal/suppression"
+	"email-tracking-server/internal/templates"
+	"email-tracking-server/pkg/logger"
+)
+
+// Erasure modes. Delete removes the subject's tracking entries outright;
+// pseudonymize keeps them for reporting with the address replaced and the
+// email's subject and content removed.
+const (
+	EraseModeDelete       = "delete"
+	EraseModePseudonymize = "pseudonymize"
+)
+
+// personalMetadata are metadata keys removed from a pseudonymized entry sent
+// to the subject.
+var personalMetadata = []string{
+	"subject",
+	"content",
+	"textContent",
+	templates.MetadataMergeFields,
+	templates.MetadataUnsubscribeURL,
+	templates.MetadataPreferencesURL,
+}
+
+// addressMetadata are metadata keys that may hold the subject's address.
+var addressMetadata = []string{"recipient", "to", "reviewerEmail", "approvedBy", "rejectedBy"}
+
+// retainedOnErase lists copies of an erased email's content that this server
+// cannot delete. They are named in the erasure's audit entry.
+var retainedOnErase = []string{
+	"Temporal workflow history, until the namespace retention period ends",
+	"email provider logs",
+}
+
+// activeWorkflowStatuses are workflow states that may still send the email.
+var activeWorkflowStatuses = map[string]bool{
+	"started":           true,
+	"scheduled":         true,
+	"awaiting_approval": true,
+	"approved":          true,
+}
+
+type PrivacyHandler struct {
+	emails       *EmailHandler
+	suppressions *suppression.Store
+	preferences  *preferences.Store
+	audit        *audit.Log
+	logger       *logger.Logger
+}
+
+type SubjectRequest struct {
+	Email string `json:"email"`
+	Mode  string `json:"mode,omitempty"`
+}
+
+type SubjectExport struct {
+	Email           string                    `json:"email"`
+	ExportedAt      time.Time                 `json:"exportedAt"`
+	TrackingEntries []EmailTrackingEntry      `json:"trackingEntries"`
+	Events          map[string][]events.Event `json:"events"`
+	Suppressions    []suppression.Entry       `json:"suppressions"`
+	Preferences     preferences.Preferences   `json:"preferences"`
+}
+
+func NewPrivacyHandler(emails *EmailHandler, suppressions *suppression.Store, prefs *preferences.Store, auditLog *audit.Log, log *logger.Logger) *PrivacyHandler {
+	return &PrivacyHandler{
+		emails:       emails,
+		suppressions: suppressions,
+		preferences:  prefs,
+		audit:        auditLog,
+		logger:       log,
+	}
+}
+
+// ExportSubject returns every tracking entry, event, suppression record and
+// preference held for an address within the tenant.
+func (ph *PrivacyHandler) ExportSubject(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+	tenantID := principal.TenantID
+
+	email, ok := ph.decodeSubject(w, r, nil)
+	if !ok {
+		return
+	}
+
+	export := SubjectExport{
+		Email:           email,
+		ExportedAt:      time.Now().UTC(),
+		TrackingEntries: []EmailTrackingEntry{},
+		Events:          map[string][]events.Event{},
+		Suppressions:    ph.subjectSuppressions(tenantID, email),
+		Preferences:     ph.preferences.Get(tenantID, email),
+	}
+	for _, entry := range ph.subjectEntries(tenantID, email) {
+		export.TrackingEntries = append(export.TrackingEntries, entry)
+		export.Events[entry.ID] = ph.emails.events.List(entry.ID)
+	}
+
+	ph.audit.Record(tenantID, events.UserActor(userID), audit.ActionSubjectExport, ph.audit.SubjectHash(tenantID, email), map[string]interface{}{
+		"trackingEntries": len(export.TrackingEntries),
+		"suppressions":    len(export.Suppressions),
+	})
+	ph.logger.WithContext(r.Context()).Info("Exported subject data", "tenant_id", tenantID, "tracking_entries", len(export.TrackingEntries))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="subject-export.json"`)
+	json.NewEncoder(w).Encode(export)
+}
+
+// EraseSubject erases an address from the tenant's tracking data. Entries
+// sent to the address are deleted or pseudonymized according to the mode;
+// entries where it was only copied, replied to or reviewing keep their
+// content with the address replaced. Sends still in flight are cancelled.
+// Preferences are removed. Tenant suppressions are removed on delete and kept
+// on pseudonymize, so the address stays blocked.
+func (ph *PrivacyHandler) EraseSubject(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+	tenantID := principal.TenantID
+	logger := ph.logger.WithContext(r.Context())
+
+	var req SubjectRequest
+	email, ok := ph.decodeSubject(w, r, &req)
+	if !ok {
+		return
+	}
+	if req.Mode != EraseModeDelete && req.Mode != EraseModePseudonymize {
+		http.Error(w, "mode must be delete or pseudonymize", http.StatusBadRequest)
+		return
+	}
+
+	subject := ph.audit.SubjectHash(tenantID, email)
+	pseudonym := "erased-" + subject[:16] + "@erased.invalid"
+
+	deleted, pseudonymized, cancelled := 0, 0, 0
+	var blobKeys []string
+	for _, entry := range ph.subjectEntries(tenantID, email) {
+		recipient, _ := entry.Metadata["recipient"].(string)
+		primary := strings.EqualFold(strings.TrimSpace(recipient), email)
+
+		if primary && ph.cancelWorkflow(entry) {
+			cancelled++
+		}
+		if primary {
+			for _, attachment := range entry.Attachments {
+				blobKeys = append(blobKeys, attachment.BlobKey)
+			}
+		}
+
+		if primary && req.Mode == EraseModeDelete {
+			ph.emails.deleteEntry(entry.ID)
+			ph.emails.events.Remove(entry.ID)
+			deleted++
+			continue
+		}
+
+		ph.emails.updateEntry(entry.ID, func(entry *EmailTrackingEntry) bool {
+			*entry = pseudonymizeEntry(*entry, email, pseudonym, primary)
+			return true
+		})
+		ph.emails.events.Scrub(entry.ID, email, pseudonym)
+		pseudonymized++
+	}
+
+	// Suppressions are kept in both modes so the erased address is never
+	// mailed again; only their free-text notes are cleared
+	suppressionsRedacted := 0
+	if ph.suppressions.Redact(tenantID, email, "erased") {
+		suppressionsRedacted++
+	}
+	preferencesRemoved := ph.preferences.Remove(tenantID, email)
+	attachmentsDeleted, attachmentsRetained := ph.deleteBlobs(blobKeys)
+
+	details := map[string]interface{}{
+		"mode":                 req.Mode,
+		"entriesDeleted":       deleted,
+		"entriesPseudonymized": pseudonymized,
+		"workflowsCancelled":   cancelled,
+		"suppressionsRedacted": suppressionsRedacted,
+		"preferencesRemoved":   preferencesRemoved,
+		"attachmentsDeleted":   attachmentsDeleted,
+		"attachmentsRetained":  attachmentsRetained,
+		"retained":             retainedOnErase,
+	}
+	record := ph.audit.Record(tenantID, events.UserActor(userID), audit.ActionSubjectErase, subject, details)
+	logger.Info("Erased subject data", "tenant_id", tenantID, "audit_id", record.ID, "mode", req.Mode, "entries_deleted", deleted, "entries_pseudonymized", pseudonymized)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
+
+// GetAuditTrail lists the tenant's audited privacy actions.
+func (ph *PrivacyHandler) GetAuditTrail(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	tenantID := principal.TenantID
+
+	entries := ph.audit.List(tenantID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"audit": entries,
+		"count": len(entries),
+	})
+}
+
+// decodeSubject reads the request body into req (or a throwaway request) and
+// returns the normalized subject address.
+func (ph *PrivacyHandler) decodeSubject(w http.ResponseWriter, r *http.Request, req *SubjectRequest) (string, bool) {
+	if req == nil {
+		req = &SubjectRequest{}
+	}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return "", false
+	}
+	email, err := suppression.Normalize(req.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return email, true
+}
+
+// deleteBlobs deletes the attachment blobs of erased entries. Blobs are
+// content-addressed, so one still attached to another entry is kept; it is
+// counted as retained along with any that failed to delete.
+func (ph *PrivacyHandler) deleteBlobs(keys []string) (int, int) {
+	deleted, retained := 0, 0
+	done := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key == "" || done[key] {
+			continue
+		}
+		done[key] = true
+
+		inUse := ph.emails.findEntries(func(entry EmailTrackingEntry) bool {
+			for _, attachment := range entry.Attachments {
+				if attachment.BlobKey == key {
+					return true
+				}
+			}
+			return false
+		})
+		if len(inUse) > 0 {
+			retained++
+			continue
+		}
+		if err := ph.emails.blobs.Delete(key); err != nil && !errors.Is(err, blobstore.ErrNotFound) {
+			ph.logger.Warn("Failed to delete attachment blob", "error", err)
+			retained++
+			continue
+		}
+		deleted++
+	}
+	return deleted, retained
+}
+
+// subjectEntries returns the tenant's tracking entries that mention email as
+// recipient, CC, BCC, reply-to or reviewer.
+func (ph *PrivacyHandler) subjectEntries(tenantID, email string) []EmailTrackingEntry {
+	return ph.emails.findEntries(func(entry EmailTrackingEntry) bool {
+		return entry.TenantID == tenantID && mentions(entry, email)
+	})
+}
+
+func (ph *PrivacyHandler) subjectSuppressions(tenantID, email string) []suppression.Entry {
+	result := []suppression.Entry{}
+	for _, entry := range ph.suppressions.List(tenantID) {
+		if entry.Email == email {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// cancelWorkflow cancels the entry's send if it may still go out and reports
+// whether a cancellation was requested.
+func (ph *PrivacyHandler) cancelWorkflow(entry EmailTrackingEntry) bool {
+	status, _ := entry.Metadata["workflowStatus"].(string)
+	workflowID, _ := entry.Metadata["workflowId"].(string)
+	if !activeWorkflowStatuses[status] || workflowID == "" || ph.emails.temporalClient == nil {
+		return false
+	}
+	runID, _ := entry.Metadata["workflowRunId"].(string)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := ph.emails.temporalClient.CancelWorkflow(ctx, workflowID, runID); err != nil {
+		ph.logger.Warn("Failed to cancel workflow during erasure", "error", err, "entry_id", entry.ID)
+		return false
+	}
+	return true
+}
+
+func mentions(entry EmailTrackingEntry, email string) bool {
+	for _, key := range addressMetadata {
+		if value, _ := entry.Metadata[key].(string); strings.EqualFold(strings.TrimSpace(value), email) {
+			return true
+		}
+	}
+	if strings.EqualFold(entry.ReplyTo, email) {
+		return true
+	}
+	for _, addr := range append(append([]string{}, entry.Cc...), entry.Bcc...) {
+		if strings.EqualFold(strings.TrimSpace(addr), email) {
+			return true
+		}
+	}
+	return false
+}
+
+// pseudonymizeEntry returns a copy of entry with email replaced by pseudonym.
+// For entries sent to the subject the email's subject and content go too.
+func pseudonymizeEntry(entry EmailTrackingEntry, email, pseudonym string, primary bool) EmailTrackingEntry {
+	replace := func(addrs []string) []string {
+		if addrs == nil {
+			return nil
+		}
+		result := make([]string, len(addrs))
+		for i, addr := range addrs {
+			if strings.EqualFold(strings.TrimSpace(addr), email) {
+				addr = pseudonym
+			}
+			result[i] = addr
+		}
+		return result
+	}
+
+	metadata := make(map[string]interface{}, len(entry.Metadata))
+	for k, v := range entry.Metadata {
+		metadata[k] = v
+	}
+	for _, key := range addressMetadata {
+		if value, _ := metadata[key].(string); strings.EqualFold(strings.TrimSpace(value), email) {
+			metadata[key] = pseudonym
+		}
+	}
+	if primary {
+		for _, key := range personalMetadata {
+			delete(metadata, key)
+		}
+		metadata["pseudonymized"] = true
+		entry.Attachments = nil
+	}
+
+	entry.Metadata = metadata
+	entry.Cc = replace(entry.Cc)
+	entry.Bcc = replace(entry.Bcc)
+	if strings.EqualFold(entry.ReplyTo, email) {
+		entry.ReplyTo = pseudonym
+	}
+	return entry
+}
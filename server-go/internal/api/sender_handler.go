@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"email-tracking-server/internal/activities"
+	"email-tracking-server/internal/client"
+	"email-tracking-server/internal/senders"
+	"email-tracking-server/pkg/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// verificationTTL is how long a sender verification link stays valid.
+const verificationTTL = 48 * time.Hour
+
+type SenderHandler struct {
+	store          *senders.Store
+	temporalClient *client.TemporalClient
+	taskQueue      string
+	jwtSecret      string
+	publicURL      string
+	logger         *logger.Logger
+}
+
+type SenderIdentityRequest struct {
+	Email       string `json:"email"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+func NewSenderHandler(store *senders.Store, temporalClient *client.TemporalClient, taskQueue string, jwtSecret string, publicURL string, log *logger.Logger) *SenderHandler {
+	return &SenderHandler{
+		store:          store,
+		temporalClient: temporalClient,
+		taskQueue:      taskQueue,
+		jwtSecret:      jwtSecret,
+		publicURL:      strings.TrimRight(publicURL, "/"),
+		logger:         log,
+	}
+}
+
+func (sh *SenderHandler) CreateSenderIdentity(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+	tenantID := principal.TenantID
+	logger := sh.logger.WithContext(r.Context())
+
+	var req SenderIdentityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Invalid JSON payload", "error", err)
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if req.Email == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+
+	identity, err := sh.store.Create(tenantID, userID, req.Email, strings.TrimSpace(req.DisplayName))
+	if err != nil {
+		logger.Error("Failed to create sender identity", "error", err)
+		sh.writeStoreError(w, err)
+		return
+	}
+
+	logger.Info("Created sender identity", "sender_identity_id", identity.ID, "tenant_id", tenantID)
+	go sh.startVerification(identity)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(identity)
+}
+
+func (sh *SenderHandler) GetSenderIdentities(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	tenantID := principal.TenantID
+
+	identities := sh.store.List(tenantID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"senderIdentities": identities,
+		"count":            len(identities),
+	})
+}
+
+func (sh *SenderHandler) DeleteSenderIdentity(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	tenantID := principal.TenantID
+	id := mux.Vars(r)["id"]
+
+	if err := sh.store.Delete(tenantID, id); err != nil {
+		sh.writeStoreError(w, err)
+		return
+	}
+
+	sh.logger.WithContext(r.Context()).Info("Deleted sender identity", "sender_identity_id", id, "tenant_id", tenantID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (sh *SenderHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	tenantID := principal.TenantID
+	id := mux.Vars(r)["id"]
+
+	identity, err := sh.store.Get(tenantID, id)
+	if err != nil {
+		sh.writeStoreError(w, err)
+		return
+	}
+	if identity.Status == senders.StatusVerified {
+		http.Error(w, "sender identity is already verified", http.StatusConflict)
+		return
+	}
+
+	go sh.startVerification(identity)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(identity)
+}
+
+func (sh *SenderHandler) SetDefaultSender(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	tenantID := principal.TenantID
+	id := mux.Vars(r)["id"]
+
+	identity, err := sh.store.SetDefault(tenantID, id)
+	if err != nil {
+		sh.writeStoreError(w, err)
+		return
+	}
+
+	sh.logger.WithContext(r.Context()).Info("Set default sender identity", "sender_identity_id", id, "tenant_id", tenantID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(identity)
+}
+
+// VerifySender handles the link from the verification email. GET only shows
+// a confirmation form so link scanners that prefetch URLs cannot verify an
+// address; the POST from that form does the verification.
+func (sh *SenderHandler) VerifySender(w http.ResponseWriter, r *http.Request) {
+	logger := sh.logger.WithContext(r.Context())
+
+	tokenString := r.FormValue("token")
+	if tokenString == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	id, email, err := senders.ParseVerificationToken(sh.jwtSecret, tokenString)
+	if err != nil {
+		logger.Warn("Invalid sender verification token", "error", err)
+		http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodGet {
+		fmt.Fprintf(w, `<html><body><h3>Verify sender address</h3><p>Confirm that you own <strong>%s</strong> and want to send email from it.</p><form method="POST" action="/verify-sender"><input type="hidden" name="token" value="%s"><button type="submit">Verify address</button></form></body></html>`,
+			html.EscapeString(email), html.EscapeString(tokenString))
+		return
+	}
+
+	identity, err := sh.store.MarkVerified(id, email)
+	if err != nil {
+		logger.Warn("Sender identity verification failed", "error", err, "sender_identity_id", id)
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "<html><body><h3>Sender not found</h3><p>This sender address no longer exists. Register it again to get a new verification link.</p></body></html>")
+		return
+	}
+
+	logger.Info("Sender identity verified", "sender_identity_id", identity.ID, "tenant_id", identity.TenantID)
+	fmt.Fprintf(w, "<html><body><h3>Sender verified</h3><p><strong>%s</strong> can now be used as a From address.</p></body></html>", html.EscapeString(identity.Email))
+}
+
+// startVerification emails a fresh confirmation link to the identity's address.
+func (sh *SenderHandler) startVerification(identity senders.Identity) {
+	token, err := senders.IssueVerificationToken(sh.jwtSecret, identity, verificationTTL)
+	if err != nil {
+		sh.logger.Error("Failed to issue sender verification token", "error", err, "sender_identity_id", identity.ID)
+		return
+	}
+
+	data := activities.SenderVerificationData{
+		IdentityID:  identity.ID,
+		TenantID:    identity.TenantID,
+		Email:       identity.Email,
+		DisplayName: identity.DisplayName,
+		VerifyURL:   fmt.Sprintf("%s/verify-sender?token=%s", sh.publicURL, url.QueryEscape(token)),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	workflowID := fmt.Sprintf("sender-verification-%s-%d", identity.ID, time.Now().UnixNano())
+	if _, err := sh.temporalClient.StartSenderVerificationWorkflow(ctx, workflowID, sh.taskQueue, data); err != nil {
+		sh.logger.Error("Failed to start sender verification workflow", "error", err, "sender_identity_id", identity.ID)
+	}
+}
+
+func (sh *SenderHandler) writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, senders.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, senders.ErrDuplicate):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
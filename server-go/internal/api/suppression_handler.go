@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"email-tracking-server/internal/suppression"
+	"email-tracking-server/pkg/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// manualReasons are the reasons a tenant may set through the API; bounces
+// and complaints come from provider webhooks.
+var manualReasons = map[string]bool{
+	suppression.ReasonManual:      true,
+	suppression.ReasonUnsubscribe: true,
+}
+
+type SuppressionHandler struct {
+	store  *suppression.Store
+	logger *logger.Logger
+}
+
+type SuppressionRequest struct {
+	Email  string `json:"email"`
+	Reason string `json:"reason,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+// SuppressionCheckRequest is sent by the worker before each send.
+type SuppressionCheckRequest struct {
+	TenantID string   `json:"tenantId"`
+	Emails   []string `json:"emails"`
+}
+
+func NewSuppressionHandler(store *suppression.Store, log *logger.Logger) *SuppressionHandler {
+	return &SuppressionHandler{
+		store:  store,
+		logger: log,
+	}
+}
+
+func (sh *SuppressionHandler) GetSuppressions(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	tenantID := principal.TenantID
+
+	entries := sh.store.List(tenantID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"suppressions": entries,
+		"count":        len(entries),
+	})
+}
+
+func (sh *SuppressionHandler) CreateSuppression(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+	tenantID := principal.TenantID
+	logger := sh.logger.WithContext(r.Context())
+
+	var req SuppressionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Invalid JSON payload", "error", err)
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = suppression.ReasonManual
+	}
+	if !manualReasons[req.Reason] {
+		http.Error(w, "reason must be manual or unsubscribe", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := sh.store.Add(tenantID, req.Email, req.Reason, req.Note, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logger.Info("Added suppression", "tenant_id", tenantID, "reason", entry.Reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(entry)
+}
+
+func (sh *SuppressionHandler) DeleteSuppression(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	tenantID := principal.TenantID
+	email := mux.Vars(r)["email"]
+
+	if err := sh.store.Remove(tenantID, email); err != nil {
+		if errors.Is(err, suppression.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sh.logger.WithContext(r.Context()).Info("Removed suppression", "tenant_id", tenantID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CheckSuppressions returns which of the given addresses may not be sent to
+// for the tenant. It backs the check in the worker's SendEmail activity.
+func (sh *SuppressionHandler) CheckSuppressions(w http.ResponseWriter, r *http.Request) {
+	var req SuppressionCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	suppressed := []suppression.Entry{}
+	for _, email := range req.Emails {
+		if entry, ok := sh.store.Check(req.TenantID, email); ok {
+			suppressed = append(suppressed, entry)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"suppressed": suppressed,
+	})
+}
@@ -0,0 +1,324 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"email-tracking-server/internal/activities"
+	"email-tracking-server/internal/emailhtml"
+	"email-tracking-server/internal/templates"
+	"email-tracking-server/pkg/logger"
+
+	"github.com/gorilla/mux"
+)
+
+type TemplateHandler struct {
+	registry *templates.Registry
+	policy   *emailhtml.Policy
+	logger   *logger.Logger
+}
+
+type TemplateRequest struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Subject string `json:"subject,omitempty"`
+	HTML    string `json:"html"`
+	Publish bool   `json:"publish,omitempty"`
+}
+
+type TemplateVersionRequest struct {
+	Subject string `json:"subject,omitempty"`
+	HTML    string `json:"html"`
+	Publish bool   `json:"publish,omitempty"`
+}
+
+type PublishTemplateRequest struct {
+	Version int `json:"version"`
+}
+
+// TemplatePreviewRequest renders either a saved template version or an unsaved
+// draft (HTML) with sample merge data.
+type TemplatePreviewRequest struct {
+	TemplateID      string                 `json:"templateId,omitempty"`
+	TemplateVersion int                    `json:"templateVersion,omitempty"`
+	HTML            string                 `json:"html,omitempty"`
+	Subject         string                 `json:"subject,omitempty"`
+	Content         string                 `json:"content,omitempty"`
+	TemplateType    string                 `json:"templateType,omitempty"`
+	Recipient       string                 `json:"recipient,omitempty"`
+	MergeFields     map[string]interface{} `json:"mergeFields,omitempty"`
+}
+
+func NewTemplateHandler(registry *templates.Registry, policy *emailhtml.Policy, log *logger.Logger) *TemplateHandler {
+	return &TemplateHandler{
+		registry: registry,
+		policy:   policy,
+		logger:   log,
+	}
+}
+
+func (th *TemplateHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+	tenantID := principal.TenantID
+	logger := th.logger.WithContext(r.Context())
+
+	var req TemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Invalid JSON payload", "error", err)
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" || req.HTML == "" {
+		http.Error(w, "name and html are required", http.StatusBadRequest)
+		return
+	}
+	if violations := th.policy.Check(req.HTML); len(violations) > 0 {
+		writeViolations(w, violations)
+		return
+	}
+
+	tmpl, err := th.registry.Create(tenantID, userID, req.Name, req.Type, req.Subject, req.HTML)
+	if err != nil {
+		logger.Error("Failed to create template", "error", err)
+		th.writeRegistryError(w, err)
+		return
+	}
+
+	if req.Publish {
+		if tmpl, err = th.registry.Publish(tenantID, tmpl.ID, 1); err != nil {
+			th.writeRegistryError(w, err)
+			return
+		}
+	}
+
+	logger.Info("Created template", "template_id", tmpl.ID, "tenant_id", tenantID, "published", req.Publish)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tmpl)
+}
+
+func (th *TemplateHandler) GetTemplates(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	tenantID := principal.TenantID
+
+	tmpls := th.registry.List(tenantID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"templates": tmpls,
+		"count":     len(tmpls),
+	})
+}
+
+func (th *TemplateHandler) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	tenantID := principal.TenantID
+	id := mux.Vars(r)["id"]
+
+	tmpl, err := th.registry.Get(tenantID, id)
+	if err != nil {
+		th.writeRegistryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tmpl)
+}
+
+func (th *TemplateHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	tenantID := principal.TenantID
+	id := mux.Vars(r)["id"]
+
+	if err := th.registry.Delete(tenantID, id); err != nil {
+		th.writeRegistryError(w, err)
+		return
+	}
+
+	th.logger.Info("Deleted template", "template_id", id, "tenant_id", tenantID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (th *TemplateHandler) CreateTemplateVersion(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+	tenantID := principal.TenantID
+	logger := th.logger.WithContext(r.Context())
+	id := mux.Vars(r)["id"]
+
+	var req TemplateVersionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Invalid JSON payload", "error", err)
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	if req.HTML == "" {
+		http.Error(w, "html is required", http.StatusBadRequest)
+		return
+	}
+	if violations := th.policy.Check(req.HTML); len(violations) > 0 {
+		writeViolations(w, violations)
+		return
+	}
+
+	version, err := th.registry.AddVersion(tenantID, id, userID, req.Subject, req.HTML)
+	if err != nil {
+		th.writeRegistryError(w, err)
+		return
+	}
+
+	if req.Publish {
+		if _, err := th.registry.Publish(tenantID, id, version.Number); err != nil {
+			th.writeRegistryError(w, err)
+			return
+		}
+	}
+
+	logger.Info("Created template version", "template_id", id, "version", version.Number, "published", req.Publish)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(version)
+}
+
+func (th *TemplateHandler) GetTemplateVersion(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	tenantID := principal.TenantID
+	vars := mux.Vars(r)
+
+	number, err := strconv.Atoi(vars["version"])
+	if err != nil || number < 1 {
+		http.Error(w, "version must be a positive integer", http.StatusBadRequest)
+		return
+	}
+
+	_, version, err := th.registry.Resolve(tenantID, vars["id"], number)
+	if err != nil {
+		th.writeRegistryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version)
+}
+
+func (th *TemplateHandler) PublishTemplate(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	tenantID := principal.TenantID
+	id := mux.Vars(r)["id"]
+
+	var req PublishTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	tmpl, err := th.registry.Publish(tenantID, id, req.Version)
+	if err != nil {
+		th.writeRegistryError(w, err)
+		return
+	}
+
+	th.logger.Info("Published template version", "template_id", id, "version", req.Version)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tmpl)
+}
+
+// PreviewTemplate renders a template exactly as SendEmail would, without
+// sending anything.
+func (th *TemplateHandler) PreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	tenantID := principal.TenantID
+	logger := th.logger.WithContext(r.Context())
+
+	var req TemplatePreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("Invalid JSON payload", "error", err)
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	emailData := activities.EmailData{
+		TenantID: tenantID,
+		EmailID:  "preview",
+		Metadata: map[string]interface{}{
+			"subject":                        req.Subject,
+			"content":                        req.Content,
+			"templateType":                   req.TemplateType,
+			templates.MetadataMergeFields:    req.MergeFields,
+			templates.MetadataUnsubscribeURL: "#unsubscribe",
+		},
+	}
+
+	switch {
+	case req.TemplateID != "":
+		_, version, err := th.registry.Resolve(tenantID, req.TemplateID, req.TemplateVersion)
+		if err != nil {
+			th.writeRegistryError(w, err)
+			return
+		}
+		emailData.Template = &version
+	case req.HTML != "":
+		emailData.Template = &templates.Version{Subject: req.Subject, HTML: req.HTML}
+	}
+
+	recipient := req.Recipient
+	if recipient == "" {
+		recipient = "recipient@example.com"
+	}
+
+	rendered, err := activities.RenderEmail(emailData, recipient, th.policy)
+	if err != nil {
+		logger.Info("Template preview failed", "error", err, "template_id", req.TemplateID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rendered)
+}
+
+func (th *TemplateHandler) writeRegistryError(w http.ResponseWriter, err error) {
+	var validationErr *templates.ValidationError
+	switch {
+	case errors.Is(err, templates.ErrTemplateNotFound), errors.Is(err, templates.ErrVersionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, templates.ErrNotPublished), errors.As(err, &validationErr):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		th.logger.Error("Template registry error", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
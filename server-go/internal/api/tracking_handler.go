@@ -0,0 +1,152 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"email-tracking-server/internal/events"
+	"email-tracking-server/internal/tracking"
+
+	"github.com/gorilla/mux"
+)
+
+// TrackOpen serves the open pixel embedded in sent emails and records the
+// open against the tracking entry. The pixel is returned for every request,
+// valid or not, so the endpoint does not reveal which tokens are genuine.
+func (eh *EmailHandler) TrackOpen(w http.ResponseWriter, r *http.Request) {
+	logger := eh.logger.WithContext(r.Context())
+	defer writePixel(w)
+
+	claims, err := tracking.Parse(eh.jwtSecret, tracking.PurposeOpen, mux.Vars(r)["token"])
+	if err != nil {
+		logger.Warn("Invalid open tracking token", "error", err)
+		return
+	}
+
+	entry, ok := eh.getEntry(claims.EntryID)
+	if !ok || entry.Test {
+		return
+	}
+
+	if eh.opens.Seen(strings.Join([]string{claims.EntryID, claims.Recipient, clientIP(r), r.UserAgent()}, "|")) {
+		return
+	}
+
+	var sentAt time.Time
+	if claims.IssuedAt != nil {
+		sentAt = claims.IssuedAt.Time
+	}
+	now := time.Now().UTC()
+	machine := tracking.IsMachine(r, sentAt)
+	entry, ok = eh.updateEntry(entry.ID, func(entry *EmailTrackingEntry) bool {
+		if machine {
+			entry.MachineOpenCount++
+			return true
+		}
+		entry.OpenCount++
+		if entry.FirstOpenedAt == nil {
+			entry.FirstOpenedAt = &now
+		}
+		entry.LastOpenedAt = &now
+		return true
+	})
+	if !ok {
+		return
+	}
+	if machine {
+		logger.Info("Recorded machine open", "entry_id", entry.ID, "email_id", entry.EmailID, "user_agent", r.UserAgent())
+	} else {
+		logger.Info("Recorded open", "entry_id", entry.ID, "email_id", entry.EmailID, "open_count", entry.OpenCount)
+	}
+	eh.events.Append(entry.ID, events.TypeOpened, trackingActor(machine), map[string]interface{}{
+		"recipient": claims.Recipient,
+		"userAgent": r.UserAgent(),
+	})
+}
+
+// TrackClick records a click on a rewritten link and redirects to its
+// destination. The destination comes only from the signed token, so a
+// tampered or forged token is rejected instead of being redirected.
+func (eh *EmailHandler) TrackClick(w http.ResponseWriter, r *http.Request) {
+	logger := eh.logger.WithContext(r.Context())
+
+	claims, err := tracking.Parse(eh.jwtSecret, tracking.PurposeClick, mux.Vars(r)["token"])
+	if err != nil {
+		logger.Warn("Invalid click tracking token", "error", err)
+		http.Error(w, "invalid link", http.StatusBadRequest)
+		return
+	}
+
+	// Scanners and the recipient both get redirected; only the recording differs
+	defer http.Redirect(w, r, claims.URL, http.StatusFound)
+
+	entry, ok := eh.getEntry(claims.EntryID)
+	if !ok || entry.Test {
+		return
+	}
+	if eh.clicks.Seen(strings.Join([]string{claims.EntryID, claims.Recipient, strconv.Itoa(claims.Link), clientIP(r), r.UserAgent()}, "|")) {
+		return
+	}
+
+	var sentAt time.Time
+	if claims.IssuedAt != nil {
+		sentAt = claims.IssuedAt.Time
+	}
+	machine := tracking.IsMachine(r, sentAt)
+	entry, ok = eh.updateEntry(entry.ID, func(entry *EmailTrackingEntry) bool {
+		if machine {
+			entry.MachineClickCount++
+		} else {
+			entry.ClickCount++
+		}
+		return true
+	})
+	if !ok {
+		return
+	}
+	eh.events.Append(entry.ID, events.TypeClicked, trackingActor(machine), map[string]interface{}{
+		"recipient": claims.Recipient,
+		"link":      claims.Link,
+		"url":       claims.URL,
+		"userAgent": r.UserAgent(),
+	})
+
+	logger.Info("Recorded click",
+		"entry_id", entry.ID,
+		"email_id", entry.EmailID,
+		"link", claims.Link,
+		"url", claims.URL,
+		"machine", machine)
+}
+
+func trackingActor(machine bool) string {
+	if machine {
+		return events.ActorMachine
+	}
+	return events.ActorRecipient
+}
+
+func writePixel(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, private")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Expires", "0")
+	w.WriteHeader(http.StatusOK)
+	w.Write(tracking.Pixel)
+}
+
+// clientIP returns the originating client address, preferring the first
+// X-Forwarded-For hop set by the reverse proxy.
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+
+	"email-tracking-server/internal/events"
+	"email-tracking-server/internal/suppression"
+	"email-tracking-server/internal/tracking"
+	"email-tracking-server/pkg/logger"
+)
+
+type UnsubscribeHandler struct {
+	emails       *EmailHandler
+	suppressions *suppression.Store
+	events       *events.Log
+	jwtSecret    string
+	logger       *logger.Logger
+}
+
+func NewUnsubscribeHandler(emails *EmailHandler, suppressions *suppression.Store, eventLog *events.Log, jwtSecret string, log *logger.Logger) *UnsubscribeHandler {
+	return &UnsubscribeHandler{
+		emails:       emails,
+		suppressions: suppressions,
+		events:       eventLog,
+		jwtSecret:    jwtSecret,
+		logger:       log,
+	}
+}
+
+// Unsubscribe serves the link from List-Unsubscribe headers and unsubscribe
+// merge fields. GET shows a confirmation page so link scanners cannot
+// unsubscribe anyone; POST, either from that page or a mail client's RFC 8058
+// one-click request, adds the address to the tenant's suppression list.
+func (uh *UnsubscribeHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	logger := uh.logger.WithContext(r.Context())
+
+	tokenString := r.FormValue("token")
+	if tokenString == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := tracking.Parse(uh.jwtSecret, tracking.PurposeUnsubscribe, tokenString)
+	if err != nil || claims.TenantID == "" || claims.Recipient == "" {
+		logger.Warn("Invalid unsubscribe token", "error", err)
+		http.Error(w, "invalid unsubscribe link", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodGet {
+		fmt.Fprintf(w, `<html><body><h3>Unsubscribe</h3><p>Stop receiving these emails at <strong>%s</strong>?</p><form method="POST" action="/unsubscribe"><input type="hidden" name="token" value="%s"><button type="submit">Unsubscribe</button></form></body></html>`,
+			html.EscapeString(claims.Recipient), html.EscapeString(tokenString))
+		return
+	}
+
+	if _, err := uh.suppressions.Add(claims.TenantID, claims.Recipient, suppression.ReasonUnsubscribe, "entry "+claims.EntryID, events.ActorRecipient); err != nil {
+		logger.Error("Failed to record unsubscribe", "error", err, "entry_id", claims.EntryID)
+		http.Error(w, "failed to unsubscribe", http.StatusInternalServerError)
+		return
+	}
+	// An erased entry keeps no timeline, so the event is only recorded while
+	// the entry exists
+	if _, ok := uh.emails.getEntry(claims.EntryID); ok {
+		uh.events.Append(claims.EntryID, events.TypeUnsubscribed, events.ActorRecipient, map[string]interface{}{
+			"recipient": claims.Recipient,
+			"oneClick":  r.FormValue("List-Unsubscribe") == "One-Click",
+		})
+	}
+
+	logger.Info("Recipient unsubscribed", "entry_id", claims.EntryID, "tenant_id", claims.TenantID)
+	fmt.Fprintf(w, "<html><body><h3>You have been unsubscribed</h3><p><strong>%s</strong> will no longer receive these emails.</p></body></html>", html.EscapeString(claims.Recipient))
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+
+	"email-tracking-server/internal/jwks"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// UserTokenVerifier checks the bearer tokens the main application issues to
+// users. Asymmetric tokens (RS*/ES*) are verified against a JWKS; HS256
+// tokens signed with the shared secret are accepted only when allowed.
+type UserTokenVerifier struct {
+	keys     *jwks.KeySet
+	secret   string
+	hs256    bool
+	issuer   string
+	audience string
+}
+
+// NewUserTokenVerifier builds a verifier. keys may be nil when only HS256 is
+// used. An empty issuer or audience is not checked.
+func NewUserTokenVerifier(keys *jwks.KeySet, secret string, allowHS256 bool, issuer string, audience string) *UserTokenVerifier {
+	return &UserTokenVerifier{
+		keys:     keys,
+		secret:   secret,
+		hs256:    allowHS256 && secret != "",
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+// Methods lists the signing algorithms the verifier accepts.
+func (v *UserTokenVerifier) Methods() []string {
+	var methods []string
+	if v.keys != nil {
+		methods = append(methods, jwks.Methods...)
+	}
+	if v.hs256 {
+		methods = append(methods, "HS256")
+	}
+	return methods
+}
+
+func (v *UserTokenVerifier) Verify(tokenString string) (*JWTClaims, error) {
+	options := []jwt.ParserOption{jwt.WithValidMethods(v.Methods()), jwt.WithExpirationRequired()}
+	if v.issuer != "" {
+		options = append(options, jwt.WithIssuer(v.issuer))
+	}
+	if v.audience != "" {
+		options = append(options, jwt.WithAudience(v.audience))
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, v.keyfunc, options...)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	return claims, nil
+}
+
+func (v *UserTokenVerifier) keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		// Never fall through to the JWKS for HMAC, so a token "signed" with
+		// a public key as the secret cannot verify
+		if !v.hs256 {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(v.secret), nil
+	}
+	if v.keys == nil {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return v.keys.Keyfunc(token)
+}
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"email-tracking-server/internal/jwks"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testSecret   = "shared-secret"
+	testIssuer   = "https://app.example.com"
+	testAudience = "email-tracking"
+)
+
+// writeJWKS writes a JWKS file publishing key as kid and loads it.
+func writeJWKS(t *testing.T, kid string, key *ecdsa.PrivateKey) *jwks.KeySet {
+	t.Helper()
+	doc := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "EC",
+			"kid": kid,
+			"use": "sig",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}},
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	ks, err := jwks.NewKeySet(path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	return ks
+}
+
+func userClaims() *JWTClaims {
+	now := time.Now()
+	return &JWTClaims{
+		UserID:   "user-1",
+		TenantID: "tenant-1",
+		Role:     RoleEmployee,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+}
+
+func signES256(t *testing.T, kid string, key *ecdsa.PrivateKey, claims *JWTClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
+
+func signHS256(t *testing.T, secret []byte, claims *JWTClaims) string {
+	t.Helper()
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
+
+func TestUserTokenVerifier(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	keys := writeJWKS(t, "k1", key)
+	// The published key as an HMAC secret, for the algorithm confusion case
+	publicKey, err := key.PublicKey.ECDH()
+	if err != nil {
+		t.Fatalf("ECDH: %v", err)
+	}
+	publicKeyBytes := publicKey.Bytes()
+
+	jwksOnly := NewUserTokenVerifier(keys, testSecret, false, testIssuer, testAudience)
+	hs256Only := NewUserTokenVerifier(nil, testSecret, true, testIssuer, testAudience)
+	both := NewUserTokenVerifier(keys, testSecret, true, testIssuer, testAudience)
+
+	tests := []struct {
+		name     string
+		verifier *UserTokenVerifier
+		token    func(t *testing.T) string
+		wantErr  error
+	}{
+		{
+			name:     "ES256 against JWKS",
+			verifier: jwksOnly,
+			token:    func(t *testing.T) string { return signES256(t, "k1", key, userClaims()) },
+		},
+		{
+			name:     "HS256 when allowed",
+			verifier: hs256Only,
+			token:    func(t *testing.T) string { return signHS256(t, []byte(testSecret), userClaims()) },
+		},
+		{
+			name:     "ES256 with HS256 also allowed",
+			verifier: both,
+			token:    func(t *testing.T) string { return signES256(t, "k1", key, userClaims()) },
+		},
+		{
+			name:     "HS256 with only JWKS",
+			verifier: jwksOnly,
+			token:    func(t *testing.T) string { return signHS256(t, []byte(testSecret), userClaims()) },
+			wantErr:  jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:     "HS256 signed with the JWKS public key",
+			verifier: jwksOnly,
+			token: func(t *testing.T) string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, userClaims())
+				token.Header["kid"] = "k1"
+				signed, err := token.SignedString(publicKeyBytes)
+				if err != nil {
+					t.Fatalf("SignedString: %v", err)
+				}
+				return signed
+			},
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:     "ES256 with only HS256",
+			verifier: hs256Only,
+			token:    func(t *testing.T) string { return signES256(t, "k1", key, userClaims()) },
+			wantErr:  jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:     "HS256 with the wrong secret",
+			verifier: hs256Only,
+			token:    func(t *testing.T) string { return signHS256(t, []byte("other-secret"), userClaims()) },
+			wantErr:  jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:     "signed by a key not in the JWKS",
+			verifier: jwksOnly,
+			token:    func(t *testing.T) string { return signES256(t, "k1", otherKey, userClaims()) },
+			wantErr:  jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:     "unknown kid",
+			verifier: jwksOnly,
+			token:    func(t *testing.T) string { return signES256(t, "k2", key, userClaims()) },
+			wantErr:  jwks.ErrUnknownKey,
+		},
+		{
+			name:     "expired",
+			verifier: jwksOnly,
+			token: func(t *testing.T) string {
+				claims := userClaims()
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+				return signES256(t, "k1", key, claims)
+			},
+			wantErr: jwt.ErrTokenExpired,
+		},
+		{
+			name:     "expired HS256",
+			verifier: hs256Only,
+			token: func(t *testing.T) string {
+				claims := userClaims()
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+				return signHS256(t, []byte(testSecret), claims)
+			},
+			wantErr: jwt.ErrTokenExpired,
+		},
+		{
+			name:     "no expiry",
+			verifier: jwksOnly,
+			token: func(t *testing.T) string {
+				claims := userClaims()
+				claims.ExpiresAt = nil
+				return signES256(t, "k1", key, claims)
+			},
+			wantErr: jwt.ErrTokenRequiredClaimMissing,
+		},
+		{
+			name:     "wrong issuer",
+			verifier: jwksOnly,
+			token: func(t *testing.T) string {
+				claims := userClaims()
+				claims.Issuer = "https://elsewhere.example.com"
+				return signES256(t, "k1", key, claims)
+			},
+			wantErr: jwt.ErrTokenInvalidIssuer,
+		},
+		{
+			name:     "wrong audience",
+			verifier: jwksOnly,
+			token: func(t *testing.T) string {
+				claims := userClaims()
+				claims.Audience = jwt.ClaimStrings{"approve-email"}
+				return signES256(t, "k1", key, claims)
+			},
+			wantErr: jwt.ErrTokenInvalidAudience,
+		},
+		{
+			name:     "unsigned",
+			verifier: both,
+			token: func(t *testing.T) string {
+				signed, err := jwt.NewWithClaims(jwt.SigningMethodNone, userClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				if err != nil {
+					t.Fatalf("SignedString: %v", err)
+				}
+				return signed
+			},
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.verifier.Verify(tt.token(t))
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if claims.UserID != "user-1" || claims.TenantID != "tenant-1" {
+					t.Errorf("claims = %+v", claims)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUserTokenVerifierHS256NeedsSecret(t *testing.T) {
+	if methods := NewUserTokenVerifier(nil, "", true, "", "").Methods(); len(methods) != 0 {
+		t.Errorf("Methods() = %v, want none without a secret", methods)
+	}
+}
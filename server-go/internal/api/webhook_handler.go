@@ -0,0 +1,213 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"email-tracking-server/internal/events"
+	"email-tracking-server/internal/suppression"
+	"email-tracking-server/internal/webhooks"
+	"email-tracking-server/pkg/logger"
+)
+
+// maxWebhookBody caps the size of webhook payloads read into memory.
+const maxWebhookBody = 1 << 20
+
+// deliveryStatusRank orders delivery outcomes so a late or out-of-order
+// webhook never downgrades a worse outcome (a delivered event arriving after
+// a complaint leaves the entry complained).
+var deliveryStatusRank = map[string]int{
+	"delivered":  1,
+	"bounced":    2,
+	"complained": 3,
+}
+
+type WebhookHandler struct {
+	emails       *EmailHandler
+	suppressions *suppression.Store
+	verifier     *webhooks.Verifier
+	replays      *webhooks.ReplayCache
+	logger       *logger.Logger
+}
+
+// ResendWebhookEvent is the part of a Resend webhook payload we use.
+type ResendWebhookEvent struct {
+	Type      string `json:"type"`
+	CreatedAt string `json:"created_at"`
+	Data      struct {
+		EmailID string   `json:"email_id"`
+		To      []string `json:"to"`
+		Bounce  *struct {
+			Type    string `json:"type"`
+			SubType string `json:"subType"`
+			Message string `json:"message"`
+		} `json:"bounce,omitempty"`
+	} `json:"data"`
+}
+
+// NewWebhookHandler creates the provider webhook handler. A nil verifier
+// disables the endpoint.
+func NewWebhookHandler(emails *EmailHandler, suppressions *suppression.Store, verifier *webhooks.Verifier, log *logger.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		emails:       emails,
+		suppressions: suppressions,
+		verifier:     verifier,
+		replays:      webhooks.NewReplayCache(),
+		logger:       log,
+	}
+}
+
+// ResendWebhook ingests delivery events from Resend and applies them to the
+// tracking entry that sent the message.
+func (wh *WebhookHandler) ResendWebhook(w http.ResponseWriter, r *http.Request) {
+	logger := wh.logger.WithContext(r.Context())
+
+	if wh.verifier == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	deliveryID, err := wh.verifier.Verify(r.Header, body)
+	if err != nil {
+		logger.Warn("Rejected webhook", "error", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	if !wh.replays.Add(deliveryID) {
+		logger.Info("Ignoring replayed webhook", "webhook_id", deliveryID)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var event ResendWebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		logger.Error("Invalid webhook payload", "error", err, "webhook_id", deliveryID)
+		wh.replays.Remove(deliveryID)
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	eventType, status := mapResendEvent(event)
+	if eventType == "" {
+		// Events we track ourselves (opened, clicked) or don't use
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	details := map[string]interface{}{
+		"webhookId": deliveryID,
+		"resendId":  event.Data.EmailID,
+	}
+	if len(event.Data.To) > 0 {
+		details["recipients"] = event.Data.To
+	}
+	if event.Data.Bounce != nil {
+		details["bounceType"] = event.Data.Bounce.Type
+		details["bounceSubType"] = event.Data.Bounce.SubType
+		details["bounceMessage"] = event.Data.Bounce.Message
+	}
+
+	entry, ok := wh.emails.applyDeliveryEvent(event.Data.EmailID, eventType, status, details)
+	if !ok {
+		// The provider ID is recorded on the entry only when its workflow
+		// completes, so an early event may arrive first. Release the ID and
+		// answer with a retryable error so the provider delivers it again.
+		// Untracked emails (approval, sender verification) are retried
+		// until the provider gives up.
+		wh.replays.Remove(deliveryID)
+		logger.Info("No tracking entry for webhook yet", "webhook_id", deliveryID, "resend_id", event.Data.EmailID, "type", event.Type)
+		http.Error(w, "tracking entry not found", http.StatusServiceUnavailable)
+		return
+	}
+	wh.suppress(eventType, entry.TenantID, event.Data.To, deliveryID)
+
+	logger.Info("Applied delivery webhook", "webhook_id", deliveryID, "entry_id", entry.ID, "type", eventType)
+	w.WriteHeader(http.StatusOK)
+}
+
+// suppress adds the recipients of a hard bounce to the global block list and
+// those of a complaint to the sending tenant's list. A complaint on an entry
+// without a tenant is only logged.
+func (wh *WebhookHandler) suppress(eventType, tenantID string, recipients []string, deliveryID string) {
+	var reason string
+	switch eventType {
+	case events.TypeBounced:
+		reason, tenantID = suppression.ReasonBounce, suppression.GlobalTenant
+	case events.TypeComplained:
+		if tenantID == "" {
+			wh.logger.Warn("Complaint without a tenant, not suppressing", "webhook_id", deliveryID)
+			return
+		}
+		reason = suppression.ReasonComplaint
+	default:
+		return
+	}
+
+	for _, recipient := range recipients {
+		if _, err := wh.suppressions.Add(tenantID, recipient, reason, "webhook "+deliveryID, events.ActorProvider); err != nil {
+			wh.logger.Warn("Failed to suppress recipient", "error", err, "webhook_id", deliveryID, "reason", reason)
+		}
+	}
+}
+
+// mapResendEvent returns the timeline event type and, for outcomes that
+// change it, the new entry status.
+func mapResendEvent(event ResendWebhookEvent) (string, string) {
+	switch event.Type {
+	case "email.delivered":
+		return events.TypeDelivered, "delivered"
+	case "email.delivery_delayed":
+		return events.TypeDeliveryDelayed, ""
+	case "email.bounced":
+		if event.Data.Bounce != nil && strings.EqualFold(event.Data.Bounce.Type, "Transient") {
+			return events.TypeSoftBounced, ""
+		}
+		return events.TypeBounced, "bounced"
+	case "email.complained":
+		return events.TypeComplained, "complained"
+	default:
+		return "", ""
+	}
+}
+
+// applyDeliveryEvent records a provider event on the entry that sent
+// resendID, updating its status unless it already has a worse outcome.
+func (eh *EmailHandler) applyDeliveryEvent(resendID, eventType, status string, details map[string]interface{}) (EmailTrackingEntry, bool) {
+	if resendID == "" {
+		return EmailTrackingEntry{}, false
+	}
+	entry, ok := eh.updateDeliveryStatus(resendID, status)
+	if !ok {
+		return EmailTrackingEntry{}, false
+	}
+	eh.events.Append(entry.ID, eventType, events.ActorProvider, details)
+	return entry, true
+}
+
+// updateDeliveryStatus finds the entry that sent resendID and raises its
+// status, holding the store lock across the lookup and the write.
+func (eh *EmailHandler) updateDeliveryStatus(resendID, status string) (EmailTrackingEntry, bool) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	for id, entry := range eh.trackingStore {
+		if sent, _ := entry.Metadata["resendId"].(string); sent != resendID {
+			continue
+		}
+		if status != "" && deliveryStatusRank[status] > deliveryStatusRank[entry.Status] {
+			entry.Status = status
+			entry.Timestamp = time.Now().UTC()
+			eh.trackingStore[id] = entry
+		}
+		return entry.clone(), true
+	}
+	return EmailTrackingEntry{}, false
+}
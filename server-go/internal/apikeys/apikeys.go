@@ -0,0 +1,166 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyPrefix starts every API key, so keys are recognisable in headers, logs
+// and secret scanners.
+const KeyPrefix = "etk_"
+
+var (
+	ErrNotFound = errors.New("API key not found")
+	ErrInvalid  = errors.New("invalid API key")
+	ErrRevoked  = errors.New("API key has been revoked")
+)
+
+// Key is a tenant's API key. Only a hash of the secret part is kept; the
+// full key is shown once, when it is created. Prefix is the public part of
+// the key and identifies it in listings.
+type Key struct {
+	ID         string     `json:"id"`
+	TenantID   string     `json:"tenantId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  string     `json:"createdBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+
+	hash []byte
+}
+
+// IsKey reports whether s has the shape of an API key rather than a JWT.
+func IsKey(s string) bool {
+	return strings.HasPrefix(s, KeyPrefix)
+}
+
+// Store holds API keys in memory, indexed by key ID.
+type Store struct {
+	mu   sync.RWMutex
+	keys map[string]*Key
+}
+
+func NewStore() *Store {
+	return &Store{
+		keys: make(map[string]*Key),
+	}
+}
+
+// Create issues a key for the tenant and returns it with the full secret,
+// which is not stored.
+func (s *Store) Create(tenantID, userID, name string, scopes []string) (Key, string, error) {
+	idBytes, err := randomBytes(6)
+	if err != nil {
+		return Key{}, "", err
+	}
+	secretBytes, err := randomBytes(24)
+	if err != nil {
+		return Key{}, "", err
+	}
+	id := hex.EncodeToString(idBytes)
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	prefix := KeyPrefix + id
+	raw := prefix + "_" + secret
+	key := &Key{
+		ID:        "key_" + id,
+		TenantID:  tenantID,
+		Name:      name,
+		Prefix:    prefix,
+		Scopes:    append([]string{}, scopes...),
+		CreatedBy: userID,
+		CreatedAt: time.Now().UTC(),
+		hash:      hashSecret(secret),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key.ID] = key
+	return *key, raw, nil
+}
+
+// List returns the tenant's keys, revoked ones included, oldest first.
+func (s *Store) List(tenantID string) []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []Key{}
+	for _, key := range s.keys {
+		if key.TenantID == tenantID {
+			result = append(result, *key)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// Revoke disables a key. Revoked keys stay listed so their use can still be
+// traced.
+func (s *Store) Revoke(tenantID, id string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok || key.TenantID != tenantID {
+		return Key{}, ErrNotFound
+	}
+	if key.RevokedAt == nil {
+		now := time.Now().UTC()
+		key.RevokedAt = &now
+	}
+	return *key, nil
+}
+
+// Authenticate checks a full key and records its use.
+func (s *Store) Authenticate(raw string) (Key, error) {
+	rest, ok := strings.CutPrefix(raw, KeyPrefix)
+	if !ok {
+		return Key{}, ErrInvalid
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return Key{}, ErrInvalid
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys["key_"+id]
+	if !ok || subtle.ConstantTimeCompare(key.hash, hashSecret(secret)) != 1 {
+		return Key{}, ErrInvalid
+	}
+	if key.RevokedAt != nil {
+		return Key{}, ErrRevoked
+	}
+	now := time.Now().UTC()
+	key.LastUsedAt = &now
+	return *key, nil
+}
+
+func hashSecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return b, nil
+}
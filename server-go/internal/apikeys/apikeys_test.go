@@ -0,0 +1,179 @@
+package apikeys
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestKey(t *testing.T, store *Store, tenantID string, scopes ...string) (Key, string) {
+	t.Helper()
+	key, raw, err := store.Create(tenantID, "user-1", "integration", scopes)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return key, raw
+}
+
+func TestAuthenticate(t *testing.T) {
+	store := NewStore()
+	key, raw := newTestKey(t, store, "tenant-1", "emails:read")
+	revoked, revokedRaw := newTestKey(t, store, "tenant-1", "emails:read")
+	if _, err := store.Revoke("tenant-1", revoked.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	secret := strings.TrimPrefix(raw, key.Prefix+"_")
+
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr error
+	}{
+		{"valid", raw, nil},
+		{"revoked", revokedRaw, ErrRevoked},
+		{"wrong secret", key.Prefix + "_" + strings.Repeat("A", len(secret)), ErrInvalid},
+		{"secret of another key", revoked.Prefix + "_" + secret, ErrInvalid},
+		{"unknown id", KeyPrefix + "000000000000_" + secret, ErrInvalid},
+		{"missing prefix", strings.TrimPrefix(raw, KeyPrefix), ErrInvalid},
+		{"no secret", key.Prefix, ErrInvalid},
+		{"empty secret", key.Prefix + "_", ErrInvalid},
+		{"empty id", KeyPrefix + "_" + secret, ErrInvalid},
+		{"jwt", "eyJhbGciOiJIUzI1NiJ9.e30.sig", ErrInvalid},
+		{"empty", "", ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Authenticate(tt.raw)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Authenticate error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if got.ID != key.ID || got.TenantID != "tenant-1" {
+				t.Errorf("Authenticate = %+v, want key %s", got, key.ID)
+			}
+		})
+	}
+}
+
+func TestAuthenticateRecordsUse(t *testing.T) {
+	store := NewStore()
+	key, raw := newTestKey(t, store, "tenant-1", "emails:read")
+	if key.LastUsedAt != nil {
+		t.Fatal("new key already marked used")
+	}
+	if _, err := store.Authenticate(raw); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if store.List("tenant-1")[0].LastUsedAt == nil {
+		t.Error("LastUsedAt not set after use")
+	}
+}
+
+// TestKeyScopes checks a key carries exactly the scopes it was created with,
+// unaffected by later changes to the caller's slice.
+func TestKeyScopes(t *testing.T) {
+	store := NewStore()
+	scopes := []string{"emails:read", "templates:read"}
+	_, raw := newTestKey(t, store, "tenant-1", scopes...)
+	scopes[0] = "apikeys"
+
+	key, err := store.Authenticate(raw)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if want := []string{"emails:read", "templates:read"}; !reflect.DeepEqual(key.Scopes, want) {
+		t.Errorf("Scopes = %v, want %v", key.Scopes, want)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	store := NewStore()
+	key, raw := newTestKey(t, store, "tenant-1", "emails:read")
+
+	tests := []struct {
+		name     string
+		tenantID string
+		id       string
+		wantErr  error
+	}{
+		{"other tenant", "tenant-2", key.ID, ErrNotFound},
+		{"unknown id", "tenant-1", "key_unknown", ErrNotFound},
+		{"own key", "tenant-1", key.ID, nil},
+		{"already revoked", "tenant-1", key.ID, nil},
+	}
+	var firstRevokedAt string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revoked, err := store.Revoke(tt.tenantID, tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Revoke error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if revoked.RevokedAt == nil {
+				t.Fatal("RevokedAt not set")
+			}
+			// Revoking again keeps the original time
+			if firstRevokedAt == "" {
+				firstRevokedAt = revoked.RevokedAt.String()
+			} else if revoked.RevokedAt.String() != firstRevokedAt {
+				t.Errorf("RevokedAt = %s, want %s", revoked.RevokedAt, firstRevokedAt)
+			}
+		})
+	}
+
+	if _, err := store.Authenticate(raw); !errors.Is(err, ErrRevoked) {
+		t.Errorf("Authenticate after revoke error = %v, want %v", err, ErrRevoked)
+	}
+	if keys := store.List("tenant-1"); len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("List after revoke = %+v, want the revoked key", keys)
+	}
+}
+
+// TestSecretNotStored checks the secret half of a key appears in neither the
+// stored key nor its JSON, only in the value returned by Create.
+func TestSecretNotStored(t *testing.T) {
+	store := NewStore()
+	key, raw := newTestKey(t, store, "tenant-1", "emails:read")
+	secret := strings.TrimPrefix(raw, key.Prefix+"_")
+
+	if !strings.HasPrefix(raw, key.Prefix+"_") {
+		t.Errorf("raw key %q does not start with prefix %q", raw, key.Prefix)
+	}
+	if string(store.keys[key.ID].hash) == secret {
+		t.Error("secret stored unhashed")
+	}
+	data, err := json.Marshal(store.List("tenant-1"))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Errorf("listing exposes the secret: %s", data)
+	}
+}
+
+func TestIsKey(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"etk_0123456789ab_secret", true},
+		{"etk_", true},
+		{"eyJhbGciOiJIUzI1NiJ9.e30.sig", false},
+		{"ETK_0123456789ab_secret", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsKey(tt.value); got != tt.want {
+			t.Errorf("IsKey(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package approval issues and verifies the signed tokens in reviewer approval
+// links. The server and the worker share it so both agree on the claims, the
+// keys and the ID of the workflow a link signals.
+package approval
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ActionApprove = "approve"
+	ActionReject  = "reject"
+
+	// Audience is the aud claim of every approval token, so tokens issued for
+	// other purposes with the same key are not accepted as approvals.
+	Audience = "approve-email"
+
+	DefaultIssuer = "email-tracking-server"
+	DefaultKeyID  = "v1"
+	DefaultTTL    = 7 * 24 * time.Hour
+
+	workflowIDPrefix = "reviewer-email-workflow-"
+)
+
+var (
+	ErrNoKey      = errors.New("approval: no signing key configured")
+	ErrUnknownKey = errors.New("approval: token signed with an unknown key")
+	ErrInvalid    = errors.New("approval: invalid token")
+)
+
+// WorkflowID returns the ID of the reviewer approval workflow for an email.
+// Approval links signal this workflow.
+func WorkflowID(emailID string) string {
+	return workflowIDPrefix + emailID
+}
+
+// Key is an HMAC key identified by the kid header of the tokens it signs.
+type Key struct {
+	ID     string
+	Secret string
+}
+
+// ParseKeys parses "id:secret" entries, the form previous keys are configured
+// in.
+func ParseKeys(entries []string) ([]Key, error) {
+	keys := make([]Key, 0, len(entries))
+	for _, entry := range entries {
+		id, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("approval: key entry must be id:secret")
+		}
+		keys = append(keys, Key{ID: id, Secret: secret})
+	}
+	return keys, nil
+}
+
+// Claims identify the email and workflow a link acts on and the reviewer it
+// was issued to. Subject is the reviewer's email address and ID (jti) lets the
+// server refuse a second use of the same link.
+type Claims struct {
+	EmailID    string `json:"emailId"`
+	WorkflowID string `json:"workflowId"`
+	ReviewerID string `json:"reviewerId,omitempty"`
+	Action     string `json:"action"`
+	jwt.RegisteredClaims
+}
+
+// Signer issues approval tokens with the current key and verifies tokens
+// signed with the current or any previous key, so keys can be rotated without
+// invalidating links already in reviewers' inboxes.
+type Signer struct {
+	current Key
+	keys    map[string]Key
+	issuer  string
+	ttl     time.Duration
+}
+
+// NewSigner creates a Signer that signs with current and also accepts tokens
+// signed with previous. An empty issuer or non-positive ttl uses the default.
+func NewSigner(current Key, previous []Key, issuer string, ttl time.Duration) (*Signer, error) {
+	if current.Secret == "" {
+		return nil, ErrNoKey
+	}
+	if current.ID == "" {
+		current.ID = DefaultKeyID
+	}
+	if issuer == "" {
+		issuer = DefaultIssuer
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	keys := map[string]Key{current.ID: current}
+	for _, key := range previous {
+		if _, exists := keys[key.ID]; exists {
+			return nil, fmt.Errorf("approval: duplicate key id %q", key.ID)
+		}
+		keys[key.ID] = key
+	}
+	return &Signer{current: current, keys: keys, issuer: issuer, ttl: ttl}, nil
+}
+
+// TTL is how long issued tokens stay valid.
+func (s *Signer) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue signs a token that lets reviewerEmail take action on an email.
+func (s *Signer) Issue(emailID, reviewerEmail, reviewerID, action string) (string, error) {
+	return s.IssueWithTTL(emailID, reviewerEmail, reviewerID, action, s.ttl)
+}
+
+// IssueWithTTL is Issue with the token valid for ttl, so links can expire
+// when the workflow stops waiting for them. A non-positive ttl uses the
+// signer's.
+func (s *Signer) IssueWithTTL(emailID, reviewerEmail, reviewerID, action string, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		ttl = s.ttl
+	}
+	if emailID == "" || reviewerEmail == "" {
+		return "", fmt.Errorf("approval: email id and reviewer are required")
+	}
+	if action != ActionApprove && action != ActionReject {
+		return "", fmt.Errorf("approval: unknown action %q", action)
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		EmailID:    emailID,
+		WorkflowID: WorkflowID(emailID),
+		ReviewerID: reviewerID,
+		Action:     action,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   reviewerEmail,
+			Audience:  jwt.ClaimStrings{Audience},
+			ID:        newTokenID(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	})
+	token.Header["kid"] = s.current.ID
+	return token.SignedString([]byte(s.current.Secret))
+}
+
+// Verify checks a token's signature, issuer, audience and expiry, and that it
+// is bound to a reviewer and names an action on the email's approval workflow.
+func (s *Signer) Verify(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		return []byte(key.Secret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(Audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" || claims.ID == "" || claims.EmailID == "" || claims.WorkflowID != WorkflowID(claims.EmailID) {
+		return nil, ErrInvalid
+	}
+	if claims.Action != ActionApprove && claims.Action != ActionReject {
+		return nil, ErrInvalid
+	}
+	return claims, nil
+}
+
+func newTokenID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,266 @@
+package approval
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	currentKey  = Key{ID: "v2", Secret: "current-secret"}
+	previousKey = Key{ID: "v1", Secret: "previous-secret"}
+)
+
+func newTestSigner(t *testing.T) *Signer {
+	t.Helper()
+	signer, err := NewSigner(currentKey, []Key{previousKey}, "", 0)
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	return signer
+}
+
+// validClaims are the claims Issue would produce for a reviewer approving
+// email-1.
+func validClaims() Claims {
+	now := time.Now()
+	return Claims{
+		EmailID:    "email-1",
+		WorkflowID: WorkflowID("email-1"),
+		Action:     ActionApprove,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    DefaultIssuer,
+			Subject:   "reviewer@example.com",
+			Audience:  jwt.ClaimStrings{Audience},
+			ID:        "jti-1",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+}
+
+func sign(t *testing.T, key Key, claims Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString([]byte(key.Secret))
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
+
+// withPayload swaps the payload of a signed token, keeping its header and
+// signature.
+func withPayload(t *testing.T, token string, claims Claims) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	parts := strings.Split(token, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+	return strings.Join(parts, ".")
+}
+
+func TestVerify(t *testing.T) {
+	signer := newTestSigner(t)
+	issued, err := signer.Issue("email-1", "reviewer@example.com", "user-7", ActionReject)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   func(t *testing.T) string
+		wantErr error
+	}{
+		{
+			name:  "issued token",
+			token: func(t *testing.T) string { return issued },
+		},
+		{
+			name:  "signed with current key",
+			token: func(t *testing.T) string { return sign(t, currentKey, validClaims()) },
+		},
+		{
+			name:  "signed with previous key",
+			token: func(t *testing.T) string { return sign(t, previousKey, validClaims()) },
+		},
+		{
+			name: "modified signature",
+			token: func(t *testing.T) string {
+				// The valid signature of different claims
+				other := validClaims()
+				other.ID = "jti-2"
+				token := sign(t, currentKey, validClaims())
+				forged := sign(t, currentKey, other)
+				return token[:strings.LastIndex(token, ".")] + forged[strings.LastIndex(forged, "."):]
+			},
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name: "modified payload",
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims.EmailID = "email-2"
+				claims.WorkflowID = WorkflowID("email-2")
+				return withPayload(t, sign(t, currentKey, validClaims()), claims)
+			},
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+				return sign(t, currentKey, claims)
+			},
+			wantErr: jwt.ErrTokenExpired,
+		},
+		{
+			name: "no expiry",
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims.ExpiresAt = nil
+				return sign(t, currentKey, claims)
+			},
+			wantErr: jwt.ErrTokenRequiredClaimMissing,
+		},
+		{
+			name: "wrong audience",
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims.Audience = jwt.ClaimStrings{"tracking"}
+				return sign(t, currentKey, claims)
+			},
+			wantErr: jwt.ErrTokenInvalidAudience,
+		},
+		{
+			name: "wrong issuer",
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims.Issuer = "someone-else"
+				return sign(t, currentKey, claims)
+			},
+			wantErr: jwt.ErrTokenInvalidIssuer,
+		},
+		{
+			name: "unknown kid",
+			token: func(t *testing.T) string {
+				return sign(t, Key{ID: "v0", Secret: previousKey.Secret}, validClaims())
+			},
+			wantErr: ErrUnknownKey,
+		},
+		{
+			name: "known kid, wrong key",
+			token: func(t *testing.T) string {
+				return sign(t, Key{ID: currentKey.ID, Secret: previousKey.Secret}, validClaims())
+			},
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name: "missing jti",
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims.ID = ""
+				return sign(t, currentKey, claims)
+			},
+			wantErr: ErrInvalid,
+		},
+		{
+			name: "missing reviewer",
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims.Subject = ""
+				return sign(t, currentKey, claims)
+			},
+			wantErr: ErrInvalid,
+		},
+		{
+			name: "workflow of another email",
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims.WorkflowID = WorkflowID("email-2")
+				return sign(t, currentKey, claims)
+			},
+			wantErr: ErrInvalid,
+		},
+		{
+			name: "unknown action",
+			token: func(t *testing.T) string {
+				claims := validClaims()
+				claims.Action = "send"
+				return sign(t, currentKey, claims)
+			},
+			wantErr: ErrInvalid,
+		},
+		{
+			name: "unsigned",
+			token: func(t *testing.T) string {
+				token := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims())
+				token.Header["kid"] = currentKey.ID
+				signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+				if err != nil {
+					t.Fatalf("SignedString: %v", err)
+				}
+				return signed
+			},
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := signer.Verify(tt.token(t))
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if claims.EmailID != "email-1" || claims.Subject != "reviewer@example.com" {
+					t.Errorf("claims = %+v", claims)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestIssueUniqueIDs checks every issued link gets its own jti, which the
+// server records to refuse a second use.
+func TestIssueUniqueIDs(t *testing.T) {
+	signer := newTestSigner(t)
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		token, err := signer.Issue("email-1", "reviewer@example.com", "", ActionApprove)
+		if err != nil {
+			t.Fatalf("Issue: %v", err)
+		}
+		claims, err := signer.Verify(token)
+		if err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+		if seen[claims.ID] {
+			t.Fatalf("jti %q issued twice", claims.ID)
+		}
+		seen[claims.ID] = true
+	}
+}
+
+func TestRotatedOutKey(t *testing.T) {
+	token := sign(t, previousKey, validClaims())
+	signer, err := NewSigner(currentKey, nil, "", 0)
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	if _, err := signer.Verify(token); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Verify error = %v, want %v", err, ErrUnknownKey)
+	}
+}
@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Actions recorded in the audit trail.
+const (
+	ActionSubjectExport = "subject_export"
+	ActionSubjectErase  = "subject_erase"
+)
+
+// Entry is one audited action. Subject identifies the data subject by
+// Log.SubjectHash so the trail itself holds no erased address.
+type Entry struct {
+	ID        string                 `json:"id"`
+	TenantID  string                 `json:"tenantId"`
+	Actor     string                 `json:"actor"`
+	Action    string                 `json:"action"`
+	Subject   string                 `json:"subject"`
+	Timestamp time.Time              `json:"timestamp"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// Log is an append-only, in-memory audit trail.
+type Log struct {
+	mu      sync.RWMutex
+	entries []Entry
+	seq     uint64
+	key     []byte
+}
+
+// NewLog returns an empty trail whose subjects are keyed with key, a
+// server-side secret that must stay stable for hashes to keep matching.
+func NewLog(key string) *Log {
+	return &Log{key: []byte(key)}
+}
+
+// SubjectHash returns a stable identifier for an email address within a
+// tenant: an HMAC-SHA256 under the log's key. Without the key, guessing
+// candidate addresses does not reveal which one a subject is.
+func (l *Log) SubjectHash(tenantID, email string) string {
+	mac := hmac.New(sha256.New, l.key)
+	mac.Write([]byte(tenantID + "|" + strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Record appends an entry and returns it with its ID and timestamp set.
+func (l *Log) Record(tenantID, actor, action, subject string, details map[string]interface{}) Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	entry := Entry{
+		ID:        fmt.Sprintf("aud_%d", l.seq),
+		TenantID:  tenantID,
+		Actor:     actor,
+		Action:    action,
+		Subject:   subject,
+		Timestamp: time.Now().UTC(),
+		Details:   details,
+	}
+	l.entries = append(l.entries, entry)
+	return entry
+}
+
+// List returns the tenant's entries in the order they were recorded.
+func (l *Log) List(tenantID string) []Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := []Entry{}
+	for _, entry := range l.entries {
+		if entry.TenantID == tenantID {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
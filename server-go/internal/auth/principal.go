@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"email-tracking-server/pkg/logger"
+)
+
+// contextKey is unexported so only this package can set or read the values
+// it stores in a request context.
+type contextKey int
+
+const principalKey contextKey = 0
+
+var (
+	ErrUnauthenticated = errors.New("request is not authenticated")
+	ErrNoRequestID     = errors.New("request has no request ID")
+)
+
+// Principal is the authenticated caller of a request: a user with a JWT or
+// an integration with an API key.
+type Principal struct {
+	UserID   string
+	TenantID string
+	Email    string
+	Role     string
+	Scopes   []string
+	// APIKeyID is set when the caller authenticated with an API key.
+	APIKeyID string
+}
+
+// HasScope reports whether the principal was granted scope.
+func (p Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// FromContext returns the request's principal, or ErrUnauthenticated when
+// no authentication middleware ran.
+func FromContext(ctx context.Context) (Principal, error) {
+	p, ok := ctx.Value(principalKey).(Principal)
+	if !ok || p.UserID == "" || p.TenantID == "" {
+		return Principal{}, ErrUnauthenticated
+	}
+	return p, nil
+}
+
+// WithRequestID stores id as the request's trace ID, which the logger adds
+// to every line logged with the context.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return logger.ContextWithTraceID(ctx, id)
+}
+
+// RequestIDFromContext returns the ID set by RequestID, or ErrNoRequestID
+// outside an HTTP request.
+func RequestIDFromContext(ctx context.Context) (string, error) {
+	id := logger.TraceIDFromContext(ctx)
+	if id == "" {
+		return "", ErrNoRequestID
+	}
+	return id, nil
+}
@@ -0,0 +1,98 @@
+package blobstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// keyPattern matches the content-addressed keys produced by Put, so a key
+// taken from workflow input can never escape the store directory.
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Store holds large binary payloads (attachments) outside Temporal workflow
+// input, which is limited in size. Workflows carry only the key.
+type Store interface {
+	Put(data []byte) (string, error)
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// FileStore is a content-addressed Store on a directory shared by the server
+// and the worker.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Put stores data under the hex SHA-256 of its content. Storing the same
+// content twice is a no-op.
+func (fs *FileStore) Put(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+	path := fs.path(key)
+
+	if _, err := os.Stat(path); err == nil {
+		return key, nil
+	}
+
+	// Write to a temp file first so readers never see a partial blob
+	tmp, err := os.CreateTemp(fs.dir, key+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create blob: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to store blob: %w", err)
+	}
+	return key, nil
+}
+
+func (fs *FileStore) Get(key string) ([]byte, error) {
+	if !keyPattern.MatchString(key) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(fs.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+	return data, nil
+}
+
+func (fs *FileStore) Delete(key string) error {
+	if !keyPattern.MatchString(key) {
+		return ErrNotFound
+	}
+	if err := os.Remove(fs.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	return nil
+}
+
+func (fs *FileStore) path(key string) string {
+	return filepath.Join(fs.dir, key)
+}
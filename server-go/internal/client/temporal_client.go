@@ -150,6 +150,27 @@ func (tc *TemporalClient) StartReviewerApprovalEmailWorkflow(ctx context.Context
 	return workflowRun, nil
 }
 
+func (tc *TemporalClient) StartSenderVerificationWorkflow(ctx context.Context, workflowID string, taskQueue string, input interface{}) (client.WorkflowRun, error) {
+	tc.logger.Info("Starting sender verification workflow", "workflow_id", workflowID, "task_queue", taskQueue)
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        workflowID,
+		TaskQueue: taskQueue,
+	}
+
+	workflowRun, err := tc.client.ExecuteWorkflow(ctx, workflowOptions, "SenderVerificationWorkflow", input)
+	if err != nil {
+		tc.logger.Error("Failed to start sender verification workflow", "workflow_id", workflowID, "error", err)
+		return nil, fmt.Errorf("failed to start sender verification workflow: %w", err)
+	}
+
+	tc.logger.Info("Successfully started sender verification workflow",
+		"workflow_id", workflowRun.GetID(),
+		"run_id", workflowRun.GetRunID())
+
+	return workflowRun, nil
+}
+
 func (tc *TemporalClient) SignalApproval(ctx context.Context, workflowID string, runID string, payload string) error {
 	tc.logger.Info("Signaling approval to workflow", "workflow_id", workflowID, "run_id", runID)
 	if err := tc.client.SignalWorkflow(ctx, workflowID, runID, "approval", payload); err != nil {
@@ -159,3 +180,14 @@ func (tc *TemporalClient) SignalApproval(ctx context.Context, workflowID string,
 	tc.logger.Info("Approval signal sent", "workflow_id", workflowID)
 	return nil
 }
+
+// CancelWorkflow requests cancellation of a running workflow. An empty runID
+// targets the latest run.
+func (tc *TemporalClient) CancelWorkflow(ctx context.Context, workflowID string, runID string) error {
+	tc.logger.Info("Cancelling workflow", "workflow_id", workflowID, "run_id", runID)
+	if err := tc.client.CancelWorkflow(ctx, workflowID, runID); err != nil {
+		tc.logger.Error("Failed to cancel workflow", "workflow_id", workflowID, "error", err)
+		return fmt.Errorf("failed to cancel workflow: %w", err)
+	}
+	return nil
+}
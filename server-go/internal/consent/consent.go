@@ -0,0 +1,83 @@
+package consent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// requiredTypes are the template types that may only be sent to contacts who
+// gave consent. Transactional and notification emails are sent regardless.
+var requiredTypes = map[string]bool{
+	"marketing":  true,
+	"newsletter": true,
+}
+
+// Required reports whether sending an email of templateType needs recorded
+// consent.
+func Required(templateType string) bool {
+	return requiredTypes[templateType]
+}
+
+// Record is the consent stored on a contact in the shared email_contacts
+// table.
+type Record struct {
+	Given  bool       `json:"consentGiven"`
+	Date   *time.Time `json:"consentDate,omitempty"`
+	Method string     `json:"consentMethod,omitempty"`
+}
+
+// Valid reports whether the record shows consent that was actually captured:
+// the flag alone is not enough without the date it was given.
+func (r Record) Valid() bool {
+	return r.Given && r.Date != nil && !r.Date.IsZero()
+}
+
+// Store reads contact consent from the main application's database.
+type Store struct {
+	pool *pgxpool.Pool
+}
+
+func NewStore(ctx context.Context, databaseURL string) (*Store, error) {
+	pool, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open contacts database: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to connect to contacts database: %w", err)
+	}
+	return &Store{pool: pool}, nil
+}
+
+func (s *Store) Close() {
+	s.pool.Close()
+}
+
+// Lookup returns the consent recorded for email within the tenant. It reports
+// false when the address is not a contact of the tenant. If the address was
+// added more than once, the strongest record wins.
+func (s *Store) Lookup(ctx context.Context, tenantID, email string) (Record, bool, error) {
+	var record Record
+	var method *string
+	err := s.pool.QueryRow(ctx, `
+		SELECT consent_given, consent_date, consent_method
+		FROM email_contacts
+		WHERE tenant_id = $1 AND lower(email) = lower($2)
+		ORDER BY consent_given DESC, consent_date DESC NULLS LAST
+		LIMIT 1`, tenantID, email).Scan(&record.Given, &record.Date, &method)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Record{}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, fmt.Errorf("failed to look up contact consent: %w", err)
+	}
+	if method != nil {
+		record.Method = *method
+	}
+	return record, true, nil
+}
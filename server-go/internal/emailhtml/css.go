@@ -0,0 +1,200 @@
+package emailhtml
+
+import (
+	"regexp"
+	"strings"
+)
+
+// cssRule is a single selector with its declarations. Rules with grouped
+// selectors ("h1, h2") are split into one rule per selector.
+type cssRule struct {
+	selector     string
+	declarations []declaration
+	order        int
+}
+
+type declaration struct {
+	property  string
+	value     string
+	important bool
+}
+
+var cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// parseStylesheet splits a stylesheet into rules that can be inlined and the
+// raw text of everything else (at-rules such as @media, and selectors with
+// pseudo-classes), which is kept in a residual <style> block for the clients
+// that support it.
+func parseStylesheet(css string, order *int) (rules []cssRule, residual []string) {
+	css = cssComment.ReplaceAllString(css, "")
+
+	for len(strings.TrimSpace(css)) > 0 {
+		css = strings.TrimSpace(css)
+
+		if strings.HasPrefix(css, "@") {
+			end := atRuleEnd(css)
+			residual = append(residual, strings.TrimSpace(css[:end]))
+			css = css[end:]
+			continue
+		}
+
+		open := strings.Index(css, "{")
+		if open < 0 {
+			break
+		}
+		close := strings.Index(css[open:], "}")
+		if close < 0 {
+			break
+		}
+		close += open
+
+		selectors := css[:open]
+		body := css[open+1 : close]
+		css = css[close+1:]
+
+		decls := parseDeclarations(body)
+		for _, sel := range strings.Split(selectors, ",") {
+			sel = strings.TrimSpace(sel)
+			if sel == "" {
+				continue
+			}
+			if !inlinable(sel) {
+				residual = append(residual, sel+" { "+strings.TrimSpace(body)+" }")
+				continue
+			}
+			*order++
+			rules = append(rules, cssRule{selector: sel, declarations: decls, order: *order})
+		}
+	}
+	return rules, residual
+}
+
+// atRuleEnd returns the index just past an at-rule, which is either terminated
+// by ";" (e.g. @import) or by its balanced block.
+func atRuleEnd(css string) int {
+	depth := 0
+	for i, r := range css {
+		switch r {
+		case ';':
+			if depth == 0 {
+				return i + 1
+			}
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(css)
+}
+
+func parseDeclarations(body string) []declaration {
+	var decls []declaration
+	for _, part := range strings.Split(body, ";") {
+		colon := strings.Index(part, ":")
+		if colon < 0 {
+			continue
+		}
+		prop := strings.ToLower(strings.TrimSpace(part[:colon]))
+		value := strings.TrimSpace(part[colon+1:])
+		if prop == "" || value == "" {
+			continue
+		}
+		important := false
+		if idx := strings.Index(strings.ToLower(value), "!important"); idx >= 0 {
+			important = true
+			value = strings.TrimSpace(value[:idx])
+		}
+		decls = append(decls, declaration{property: prop, value: value, important: important})
+	}
+	return decls
+}
+
+func formatDeclarations(decls []declaration) string {
+	parts := make([]string, 0, len(decls))
+	for _, d := range decls {
+		parts = append(parts, d.property+": "+d.value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// inlinable reports whether a selector only uses the simple features the
+// matcher understands: type, class, id and universal selectors combined with
+// descendant or child combinators.
+func inlinable(selector string) bool {
+	return !strings.ContainsAny(selector, ":[]+~")
+}
+
+// compound is one step of a selector such as "td.cell#total".
+type compound struct {
+	tag     string
+	id      string
+	classes []string
+	// child is true when this step must be the direct parent of the next one
+	child bool
+}
+
+// parseSelector returns the compound selectors from outermost to innermost.
+func parseSelector(selector string) []compound {
+	selector = strings.ReplaceAll(selector, ">", " > ")
+	var steps []compound
+	childNext := false
+	for _, token := range strings.Fields(selector) {
+		if token == ">" {
+			childNext = true
+			continue
+		}
+		c := compound{}
+		rest := token
+		if i := strings.IndexAny(rest, ".#"); i != 0 {
+			if i < 0 {
+				i = len(rest)
+			}
+			c.tag = strings.ToLower(rest[:i])
+			rest = rest[i:]
+		}
+		for rest != "" {
+			kind := rest[0]
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".#")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			rest = rest[end:]
+			if kind == '#' {
+				c.id = name
+			} else {
+				c.classes = append(c.classes, name)
+			}
+		}
+		if c.tag == "*" {
+			c.tag = ""
+		}
+		if childNext && len(steps) > 0 {
+			steps[len(steps)-1].child = true
+		}
+		childNext = false
+		steps = append(steps, c)
+	}
+	return steps
+}
+
+// specificity follows the CSS (ids, classes, types) ordering packed into one
+// comparable integer.
+func specificity(steps []compound) int {
+	ids, classes, tags := 0, 0, 0
+	for _, s := range steps {
+		if s.id != "" {
+			ids++
+		}
+		classes += len(s.classes)
+		if s.tag != "" {
+			tags++
+		}
+	}
+	return ids*10000 + classes*100 + tags
+}
@@ -0,0 +1,322 @@
+package emailhtml
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Options configures the post-processing pipeline.
+type Options struct {
+	// BaseURL is used to make relative image URLs absolute. Relative URLs are
+	// left untouched when it is empty.
+	BaseURL string
+}
+
+// unsupportedProperties are dropped from inline styles because the major
+// email clients ignore them or render them inconsistently.
+var unsupportedProperties = map[string]bool{
+	"position":   true,
+	"transition": true,
+	"animation":  true,
+	"transform":  true,
+	"filter":     true,
+}
+
+var gradientColor = regexp.MustCompile(`#[0-9a-fA-F]{3,8}\b|rgba?\([^)]*\)`)
+
+// Process prepares an HTML body for email clients: <style> rules are inlined
+// onto the elements they match, constructs that Gmail and Outlook strip are
+// removed or replaced with fallbacks, and relative image URLs are made
+// absolute.
+func Process(doc string, opts Options) (string, error) {
+	root, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse html: %w", err)
+	}
+
+	var base *url.URL
+	if opts.BaseURL != "" {
+		if base, err = url.Parse(opts.BaseURL); err != nil {
+			return "", fmt.Errorf("invalid base url %q: %w", opts.BaseURL, err)
+		}
+	}
+
+	rules, residual := collectStyles(root)
+	inlineRules(root, rules)
+	cleanup(root, base)
+	if len(residual) > 0 {
+		addResidualStyle(root, residual)
+	}
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, root); err != nil {
+		return "", fmt.Errorf("failed to render html: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// collectStyles parses and removes every <style> element.
+func collectStyles(root *html.Node) ([]cssRule, []string) {
+	var rules []cssRule
+	var residual []string
+	var styles []*html.Node
+	order := 0
+
+	walk(root, func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Style {
+			styles = append(styles, n)
+		}
+	})
+
+	for _, style := range styles {
+		var css strings.Builder
+		for c := style.FirstChild; c != nil; c = c.NextSibling {
+			css.WriteString(c.Data)
+		}
+		r, res := parseStylesheet(css.String(), &order)
+		rules = append(rules, r...)
+		residual = append(residual, res...)
+		style.Parent.RemoveChild(style)
+	}
+	return rules, residual
+}
+
+type matchedRule struct {
+	specificity int
+	order       int
+	decls       []declaration
+}
+
+// inlineRules applies the cascade: stylesheet rules by specificity and source
+// order, then the element's own style attribute, then !important rules.
+func inlineRules(root *html.Node, rules []cssRule) {
+	type parsed struct {
+		steps []compound
+		rule  cssRule
+	}
+	compiled := make([]parsed, 0, len(rules))
+	for _, r := range rules {
+		compiled = append(compiled, parsed{steps: parseSelector(r.selector), rule: r})
+	}
+
+	walk(root, func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			return
+		}
+
+		var matched []matchedRule
+		for _, p := range compiled {
+			if matches(n, p.steps) {
+				matched = append(matched, matchedRule{specificity: specificity(p.steps), order: p.rule.order, decls: p.rule.declarations})
+			}
+		}
+		existing := getAttr(n, "style")
+		if len(matched) == 0 && existing == "" {
+			return
+		}
+		sort.SliceStable(matched, func(i, j int) bool {
+			if matched[i].specificity != matched[j].specificity {
+				return matched[i].specificity < matched[j].specificity
+			}
+			return matched[i].order < matched[j].order
+		})
+
+		var result []declaration
+		for _, m := range matched {
+			for _, d := range m.decls {
+				if !d.important {
+					result = setDeclaration(result, d)
+				}
+			}
+		}
+		for _, d := range parseDeclarations(existing) {
+			result = setDeclaration(result, d)
+		}
+		for _, m := range matched {
+			for _, d := range m.decls {
+				if d.important {
+					result = setDeclaration(result, d)
+				}
+			}
+		}
+
+		setAttr(n, "style", formatDeclarations(emailSafe(result)))
+	})
+}
+
+func setDeclaration(decls []declaration, d declaration) []declaration {
+	for i := range decls {
+		if decls[i].property == d.property {
+			decls[i].value = d.value
+			return decls
+		}
+	}
+	return append(decls, d)
+}
+
+// emailSafe drops unsupported properties and replaces gradient backgrounds
+// with a solid background-color taken from the gradient's first stop.
+func emailSafe(decls []declaration) []declaration {
+	out := make([]declaration, 0, len(decls))
+	for _, d := range decls {
+		if unsupportedProperties[d.property] {
+			continue
+		}
+		if (d.property == "background" || d.property == "background-image") && strings.Contains(d.value, "gradient(") {
+			if color := gradientColor.FindString(d.value); color != "" {
+				out = setDeclaration(out, declaration{property: "background-color", value: color})
+			}
+			continue
+		}
+		out = setDeclaration(out, d)
+	}
+	return out
+}
+
+func matches(n *html.Node, steps []compound) bool {
+	if len(steps) == 0 {
+		return false
+	}
+	last := len(steps) - 1
+	if !matchesCompound(n, steps[last]) {
+		return false
+	}
+	return matchesAncestors(n.Parent, steps[:last])
+}
+
+func matchesAncestors(n *html.Node, steps []compound) bool {
+	if len(steps) == 0 {
+		return true
+	}
+	last := len(steps) - 1
+	step := steps[last]
+	for ; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		if matchesCompound(n, step) && matchesAncestors(n.Parent, steps[:last]) {
+			return true
+		}
+		if step.child {
+			return false
+		}
+	}
+	return false
+}
+
+func matchesCompound(n *html.Node, c compound) bool {
+	if c.tag != "" && n.Data != c.tag {
+		return false
+	}
+	if c.id != "" && getAttr(n, "id") != c.id {
+		return false
+	}
+	if len(c.classes) > 0 {
+		classes := strings.Fields(getAttr(n, "class"))
+		for _, want := range c.classes {
+			found := false
+			for _, have := range classes {
+				if have == want {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// cleanup removes elements that email clients strip or that break rendering,
+// and absolutizes image URLs.
+func cleanup(root *html.Node, base *url.URL) {
+	var remove []*html.Node
+	walk(root, func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			return
+		}
+		switch n.DataAtom {
+		case atom.Script:
+			remove = append(remove, n)
+		case atom.Link:
+			if strings.EqualFold(getAttr(n, "rel"), "stylesheet") {
+				remove = append(remove, n)
+			}
+		case atom.Img:
+			if base != nil {
+				absolutize(n, "src", base)
+			}
+		case atom.Table, atom.Td, atom.Body:
+			if base != nil {
+				absolutize(n, "background", base)
+			}
+		}
+	})
+	for _, n := range remove {
+		n.Parent.RemoveChild(n)
+	}
+}
+
+func absolutize(n *html.Node, key string, base *url.URL) {
+	val := getAttr(n, key)
+	if val == "" || strings.HasPrefix(val, "data:") || strings.HasPrefix(val, "cid:") {
+		return
+	}
+	ref, err := url.Parse(val)
+	if err != nil || ref.IsAbs() {
+		return
+	}
+	setAttr(n, key, base.ResolveReference(ref).String())
+}
+
+// addResidualStyle keeps rules that cannot be inlined (media queries, :hover)
+// in a single <style> element in the document head.
+func addResidualStyle(root *html.Node, residual []string) {
+	var head *html.Node
+	walk(root, func(n *html.Node) {
+		if head == nil && n.Type == html.ElementNode && n.DataAtom == atom.Head {
+			head = n
+		}
+	})
+	if head == nil {
+		return
+	}
+	style := &html.Node{Type: html.ElementNode, Data: "style", DataAtom: atom.Style}
+	style.AppendChild(&html.Node{Type: html.TextNode, Data: "\n" + strings.Join(residual, "\n") + "\n"})
+	head.AppendChild(style)
+}
+
+func walk(n *html.Node, fn func(*html.Node)) {
+	fn(n)
+	for c := n.FirstChild; c != nil; {
+		// Capture the sibling first so fn may detach c
+		next := c.NextSibling
+		walk(c, fn)
+		c = next
+	}
+}
+
+func getAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(n *html.Node, key, val string) {
+	for i := range n.Attr {
+		if n.Attr[i].Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
@@ -0,0 +1,66 @@
+package emailhtml
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files with the current output")
+
+// TestProcess runs every testdata/*.html document through Process and
+// compares the result with the matching .golden file.
+func TestProcess(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no testdata/*.html inputs")
+	}
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".html")
+		t.Run(name, func(t *testing.T) {
+			doc, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Process(string(doc), Options{BaseURL: "https://assets.example.com/mail/"})
+			if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+
+			golden := strings.TrimSuffix(input, ".html") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("missing golden file (run go test -update): %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s\n--- got ---\n%s\n--- want ---\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+// TestProcessWithoutBaseURL checks relative URLs are left alone when no base
+// is configured.
+func TestProcessWithoutBaseURL(t *testing.T) {
+	got, err := Process(`<html><body background="bg.png"><img src="images/logo.png"></body></html>`, Options{})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	for _, want := range []string{`background="bg.png"`, `src="images/logo.png"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output %q does not contain %q", got, want)
+		}
+	}
+}
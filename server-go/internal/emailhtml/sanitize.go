@@ -0,0 +1,291 @@
+package emailhtml
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// PolicyConfig is the yaml-configurable part of a sanitizer policy. Empty
+// lists fall back to the defaults.
+type PolicyConfig struct {
+	AllowedElements   []string `yaml:"allowed_elements"`
+	AllowedAttributes []string `yaml:"allowed_attributes"`
+	AllowedURLSchemes []string `yaml:"allowed_url_schemes"`
+}
+
+// Policy is an allowlist of elements, attributes and URL schemes that
+// user-supplied campaign HTML may contain.
+type Policy struct {
+	elements   map[string]bool
+	attributes map[string]bool
+	schemes    map[string]bool
+}
+
+var defaultElements = []string{
+	"a", "abbr", "b", "blockquote", "body", "br", "center", "code", "div", "em",
+	"font", "h1", "h2", "h3", "h4", "h5", "h6", "head", "hr", "html", "i", "img",
+	"li", "meta", "ol", "p", "pre", "s", "small", "span", "strong", "style", "sub",
+	"sup", "table", "tbody", "td", "tfoot", "th", "thead", "title", "tr", "u", "ul",
+}
+
+var defaultAttributes = []string{
+	"align", "alt", "bgcolor", "border", "cellpadding", "cellspacing", "charset",
+	"class", "color", "colspan", "content", "dir", "height", "href", "id", "lang",
+	"name", "rel", "rowspan", "src", "style", "target", "title", "valign", "width",
+}
+
+var defaultSchemes = []string{"http", "https", "mailto", "tel", "cid"}
+
+// dropWithContent lists elements whose children are removed along with them;
+// other disallowed elements are unwrapped so their text survives.
+var dropWithContent = map[string]bool{
+	"script": true, "iframe": true, "object": true, "embed": true, "form": true,
+	"noscript": true, "template": true, "svg": true, "math": true, "frameset": true,
+	"frame": true, "applet": true, "select": true, "textarea": true, "button": true,
+}
+
+var urlAttributes = map[string]bool{"href": true, "src": true, "background": true, "action": true}
+
+// NewPolicy builds a policy from configuration.
+func NewPolicy(cfg PolicyConfig) *Policy {
+	return &Policy{
+		elements:   toSet(cfg.AllowedElements, defaultElements),
+		attributes: toSet(cfg.AllowedAttributes, defaultAttributes),
+		schemes:    toSet(cfg.AllowedURLSchemes, defaultSchemes),
+	}
+}
+
+// DefaultPolicy returns the built-in allowlist.
+func DefaultPolicy() *Policy {
+	return NewPolicy(PolicyConfig{})
+}
+
+func toSet(values, defaults []string) map[string]bool {
+	if len(values) == 0 {
+		values = defaults
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[strings.ToLower(strings.TrimSpace(v))] = true
+	}
+	return set
+}
+
+// Violation describes one piece of HTML the policy does not allow.
+type Violation struct {
+	Element   string `json:"element"`
+	Attribute string `json:"attribute,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+func (v Violation) String() string {
+	if v.Attribute != "" {
+		return fmt.Sprintf("<%s %s>: %s", v.Element, v.Attribute, v.Reason)
+	}
+	return fmt.Sprintf("<%s>: %s", v.Element, v.Reason)
+}
+
+// Check reports every violation in doc without modifying it. Repeated
+// violations are reported once.
+func (p *Policy) Check(doc string) []Violation {
+	root, err := parseFragment(doc)
+	if err != nil {
+		return []Violation{{Element: "#document", Reason: err.Error()}}
+	}
+
+	seen := map[Violation]bool{}
+	var violations []Violation
+	p.visit(root, func(v Violation) {
+		if !seen[v] {
+			seen[v] = true
+			violations = append(violations, v)
+		}
+	})
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].String() < violations[j].String()
+	})
+	return violations
+}
+
+// Sanitize removes everything the policy does not allow.
+func (p *Policy) Sanitize(doc string) string {
+	root, err := parseFragment(doc)
+	if err != nil {
+		return ""
+	}
+	p.visit(root, nil)
+
+	var buf bytes.Buffer
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		html.Render(&buf, c)
+	}
+	return buf.String()
+}
+
+// SanitizeDocument is Sanitize for a complete HTML document, such as a
+// rendered layout: the doctype and the html, head and body structure are
+// kept rather than flattened into body content.
+func (p *Policy) SanitizeDocument(doc string) string {
+	root, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		return ""
+	}
+	p.visit(root, nil)
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, root); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// visit walks the tree, reporting violations to report when it is non-nil
+// and otherwise removing them.
+func (p *Policy) visit(n *html.Node, report func(Violation)) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+
+		switch c.Type {
+		case html.CommentNode:
+			if report == nil {
+				n.RemoveChild(c)
+			}
+		case html.ElementNode:
+			name := strings.ToLower(c.Data)
+			if !p.elements[name] {
+				if report != nil {
+					report(Violation{Element: name, Reason: "element not allowed"})
+					p.visit(c, report)
+				} else if dropWithContent[name] {
+					n.RemoveChild(c)
+				} else {
+					// Unwrap: sanitize the children, then lift them into place
+					p.visit(c, nil)
+					for gc := c.FirstChild; gc != nil; {
+						following := gc.NextSibling
+						c.RemoveChild(gc)
+						n.InsertBefore(gc, c)
+						gc = following
+					}
+					n.RemoveChild(c)
+				}
+				break
+			}
+
+			// Stylesheets get the same checks as style attributes; an unsafe
+			// one is dropped whole
+			if name == "style" && unsafeStylesheet(textContent(c)) {
+				if report != nil {
+					report(Violation{Element: name, Reason: "stylesheet contains script, expression or import"})
+				} else {
+					n.RemoveChild(c)
+					break
+				}
+			}
+
+			p.visitAttributes(c, name, report)
+			p.visit(c, report)
+		}
+
+		c = next
+	}
+}
+
+func (p *Policy) visitAttributes(n *html.Node, element string, report func(Violation)) {
+	kept := n.Attr[:0]
+	for _, a := range n.Attr {
+		key := strings.ToLower(a.Key)
+		reason := ""
+		switch {
+		case strings.HasPrefix(key, "on"):
+			reason = "event handler attributes are not allowed"
+		case !p.attributes[key]:
+			reason = "attribute not allowed"
+		case urlAttributes[key] && !p.allowedURL(a.Val):
+			reason = "url scheme not allowed"
+		case key == "style" && unsafeStyle(a.Val):
+			reason = "style contains script or expression"
+		}
+
+		if reason == "" {
+			kept = append(kept, a)
+			continue
+		}
+		if report != nil {
+			report(Violation{Element: element, Attribute: key, Reason: reason})
+			kept = append(kept, a)
+		}
+	}
+	n.Attr = kept
+}
+
+func (p *Policy) allowedURL(raw string) bool {
+	raw = strings.TrimSpace(raw)
+	// Merge fields such as {{.UnsubscribeURL}} are filled in by the send
+	// pipeline and checked again after rendering
+	if raw == "" || strings.HasPrefix(raw, "{{") || strings.HasPrefix(raw, "#") {
+		return true
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" {
+		return true
+	}
+	return p.schemes[strings.ToLower(u.Scheme)]
+}
+
+// normalizeCSS lowercases css and strips comments, backslashes and
+// whitespace so obfuscated keywords such as "expr/**/ession(" are still found.
+func normalizeCSS(css string) string {
+	s := cssComment.ReplaceAllString(strings.ToLower(css), "")
+	return strings.Map(func(r rune) rune {
+		if r == '\\' || r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func unsafeStyle(style string) bool {
+	s := normalizeCSS(style)
+	return strings.Contains(s, "expression(") || strings.Contains(s, "javascript:") || strings.Contains(s, "behavior:")
+}
+
+// unsafeStylesheet applies the style attribute checks to a <style> element's
+// text and also rejects @import, which loads rules from elsewhere.
+func unsafeStylesheet(css string) bool {
+	return unsafeStyle(css) || strings.Contains(normalizeCSS(css), "@import")
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+	}
+	return b.String()
+}
+
+// parseFragment parses doc as body content so fragments and full documents
+// are both handled.
+func parseFragment(doc string) (*html.Node, error) {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(doc), context)
+	if err != nil {
+		return nil, err
+	}
+	root := &html.Node{Type: html.DocumentNode}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+	return root, nil
+}
@@ -0,0 +1,51 @@
+package emailhtml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeStylesheets(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		kept bool
+	}{
+		{"plain rules", `<style>.a { color: red; }</style>`, true},
+		{"expression", `<style>.a { width: expression(alert(1)); }</style>`, false},
+		{"javascript url", `<style>.a { background: url(javascript:alert(1)); }</style>`, false},
+		{"behavior", `<style>.a { behavior: url(x.htc); }</style>`, false},
+		{"comment obfuscation", `<style>.a { width: expr/**/ession(alert(1)); }</style>`, false},
+		{"import", `<style>@import url(https://evil.example/x.css);</style>`, false},
+	}
+	policy := DefaultPolicy()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.Sanitize(tt.doc)
+			if kept := strings.Contains(got, "<style>"); kept != tt.kept {
+				t.Errorf("Sanitize(%q) = %q, style kept = %v, want %v", tt.doc, got, kept, tt.kept)
+			}
+			if violations := policy.Check(tt.doc); (len(violations) == 0) != tt.kept {
+				t.Errorf("Check(%q) = %v", tt.doc, violations)
+			}
+		})
+	}
+}
+
+func TestSanitizeDocument(t *testing.T) {
+	doc := `<!DOCTYPE html><html><head><title>T</title><style>.a { color: red; }</style></head>` +
+		`<body><p onclick="x()">Hi</p><script>alert(1)</script><iframe src="https://evil.example"></iframe>` +
+		`<form action="https://evil.example"><input name="pw"></form><a href="javascript:alert(1)">x</a></body></html>`
+
+	got := DefaultPolicy().SanitizeDocument(doc)
+	for _, want := range []string{"<!DOCTYPE html>", "<head>", "<title>T</title>", "<style>", "<p>Hi</p>"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("SanitizeDocument dropped %q: %s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"onclick", "<script", "<iframe", "<form", "<input", "javascript:"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("SanitizeDocument kept %q: %s", unwanted, got)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types recorded on a tracking entry's timeline.
+const (
+	TypeCreated          = "created"
+	TypeUpdated          = "updated"
+	TypeWorkflowStarted  = "workflow_started"
+	TypeWorkflowFailed   = "workflow_failed"
+	TypeReviewerNotified = "reviewer_notified"
+	TypeApproved         = "approved"
+	TypeApprovalTimeout  = "approval_timeout"
+	TypeRejected         = "rejected"
+	TypeSending          = "sending"
+	TypeSent             = "sent"
+	TypeFailed           = "failed"
+	TypeOpened           = "opened"
+	TypeClicked          = "clicked"
+	TypeDelivered        = "delivered"
+	TypeDeliveryDelayed  = "delivery_delayed"
+	TypeBounced          = "bounced"
+	TypeSoftBounced      = "soft_bounced"
+	TypeComplained       = "complained"
+	TypeSuppressed       = "suppressed"
+	TypeUnsubscribed     = "unsubscribed"
+	TypeOptedOut         = "opted_out"
+	TypeConsentMissing   = "consent_missing"
+	TypePreferences      = "preferences_updated"
+)
+
+// Actors that record events. User actions are recorded as "user:<id>".
+const (
+	ActorSystem    = "system"
+	ActorWorkflow  = "workflow"
+	ActorReviewer  = "reviewer"
+	ActorRecipient = "recipient"
+	ActorMachine   = "machine"
+	ActorProvider  = "provider"
+)
+
+// Event is one entry in a tracking entry's timeline.
+type Event struct {
+	ID        string                 `json:"id"`
+	EntryID   string                 `json:"entryId"`
+	Type      string                 `json:"type"`
+	Timestamp time.Time              `json:"timestamp"`
+	Actor     string                 `json:"actor"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// UserActor formats the actor for an authenticated user.
+func UserActor(userID string) string {
+	return "user:" + userID
+}
+
+// Log is an append-only, in-memory event log keyed by tracking entry.
+type Log struct {
+	mu     sync.RWMutex
+	events map[string][]Event
+	seq    uint64
+}
+
+func NewLog() *Log {
+	return &Log{
+		events: make(map[string][]Event),
+	}
+}
+
+// Append records an event and returns it with its ID and timestamp set.
+func (l *Log) Append(entryID, eventType, actor string, details map[string]interface{}) Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	event := Event{
+		ID:        fmt.Sprintf("evt_%d", l.seq),
+		EntryID:   entryID,
+		Type:      eventType,
+		Timestamp: time.Now().UTC(),
+		Actor:     actor,
+		Details:   details,
+	}
+	l.events[entryID] = append(l.events[entryID], event)
+	return event
+}
+
+// List returns an entry's events in the order they were recorded.
+func (l *Log) List(entryID string) []Event {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := make([]Event, len(l.events[entryID]))
+	copy(result, l.events[entryID])
+	return result
+}
+
+// Remove drops the timeline of a deleted entry.
+func (l *Log) Remove(entryID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.events, entryID)
+}
+
+// Scrub replaces every string detail equal to value (ignoring case) in an
+// entry's events, including elements of list details such as webhook
+// recipients, and returns how many were replaced.
+func (l *Log) Scrub(entryID, value, replacement string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	matches := func(s string) bool {
+		return strings.EqualFold(strings.TrimSpace(s), value)
+	}
+	replaced := 0
+	for _, event := range l.events[entryID] {
+		for k, v := range event.Details {
+			switch v := v.(type) {
+			case string:
+				if matches(v) {
+					event.Details[k] = replacement
+					replaced++
+				}
+			case []string:
+				scrubbed := make([]string, len(v))
+				for i, s := range v {
+					if matches(s) {
+						s = replacement
+						replaced++
+					}
+					scrubbed[i] = s
+				}
+				event.Details[k] = scrubbed
+			case []interface{}:
+				scrubbed := make([]interface{}, len(v))
+				for i, item := range v {
+					if s, ok := item.(string); ok && matches(s) {
+						item = replacement
+						replaced++
+					}
+					scrubbed[i] = item
+				}
+				event.Details[k] = scrubbed
+			}
+		}
+	}
+	return replaced
+}
@@ -0,0 +1,285 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRefetchInterval limits refetches triggered by requests, for a stale set
+// or a kid the set does not know, so neither an unreachable issuer nor forged
+// kids make every request hit the JWKS endpoint. The two are limited
+// separately, so a background refresh of a stale set does not stop a token
+// signed with a newly rotated key from fetching it.
+const minRefetchInterval = time.Minute
+
+var ErrUnknownKey = errors.New("no matching key in JWKS")
+
+// Methods are the asymmetric signing algorithms accepted with a key set.
+var Methods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+// KeySet holds the public keys of a JWKS document, indexed by kid. The
+// document is read from a local file or fetched from a URL, and reloaded
+// every refresh interval and whenever a token names a kid that is not cached,
+// so keys rotated by the issuer are picked up without a restart.
+type KeySet struct {
+	source  string
+	refresh time.Duration
+	client  *http.Client
+
+	mu             sync.RWMutex
+	keys           map[string]crypto.PublicKey
+	fetchedAt      time.Time
+	lastStaleFetch time.Time
+	lastKidFetch   time.Time
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewKeySet loads the key set from source, an http(s) URL or a file path
+// (optionally prefixed with file://).
+func NewKeySet(source string, refresh time.Duration) (*KeySet, error) {
+	ks := &KeySet{
+		source:  source,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		keys:    make(map[string]crypto.PublicKey),
+	}
+	if err := ks.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Refresh reloads the key set. On failure the previously loaded keys are
+// kept.
+func (ks *KeySet) Refresh(ctx context.Context) error {
+	data, err := ks.read(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := parse(data)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = keys
+	ks.fetchedAt = time.Now()
+	return nil
+}
+
+// Key returns the key for kid. A token without a kid matches only when the
+// set holds exactly one key.
+func (ks *KeySet) Key(kid string) (crypto.PublicKey, error) {
+	ks.mu.RLock()
+	stale := time.Since(ks.fetchedAt) > ks.refresh
+	ks.mu.RUnlock()
+	if stale && ks.claimAttempt(&ks.lastStaleFetch) {
+		// Reload in the background and keep serving cached keys meanwhile,
+		// so requests are not held up by a slow or unreachable issuer
+		go ks.Refresh(context.Background())
+	}
+
+	if key, ok := ks.lookup(kid); ok {
+		return key, nil
+	}
+
+	if ks.claimAttempt(&ks.lastKidFetch) {
+		if err := ks.Refresh(context.Background()); err != nil {
+			return nil, err
+		}
+		if key, ok := ks.lookup(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: kid %q", ErrUnknownKey, kid)
+}
+
+// Keyfunc resolves the verification key for a token, checking that the key
+// type matches the token's algorithm.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, err := ks.Key(kid)
+	if err != nil {
+		return nil, err
+	}
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA:
+		if _, ok := key.(*rsa.PublicKey); ok {
+			return key, nil
+		}
+	case *jwt.SigningMethodECDSA:
+		if _, ok := key.(*ecdsa.PublicKey); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("key %q does not match signing method %v", kid, token.Header["alg"])
+}
+
+// claimAttempt reports whether a refetch limited by last may start now and,
+// if so, records the attempt so concurrent callers do not start another.
+func (ks *KeySet) claimAttempt(last *time.Time) bool {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if time.Since(*last) <= minRefetchInterval {
+		return false
+	}
+	*last = time.Now()
+	return true
+}
+
+func (ks *KeySet) lookup(kid string) (crypto.PublicKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, true
+		}
+	}
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+func (ks *KeySet) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(ks.source, "http://") && !strings.HasPrefix(ks.source, "https://") {
+		data, err := os.ReadFile(strings.TrimPrefix(ks.source, "file://"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+		}
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+	return data, nil
+}
+
+// parse decodes the RSA and EC signing keys of a JWKS document. Encryption
+// keys and unsupported key types are skipped.
+func parse(data []byte) (map[string]crypto.PublicKey, error) {
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range doc.Keys {
+		if jwk.Use == "enc" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			key, err = rsaKey(jwk)
+		case "EC":
+			key, err = ecKey(jwk)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS contains no usable signing keys")
+	}
+	return keys, nil
+}
+
+func rsaKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("invalid exponent")
+	}
+	key := &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}
+	if key.N.BitLen() < 2048 {
+		return nil, fmt.Errorf("RSA key shorter than 2048 bits")
+	}
+	return key, nil
+}
+
+func ecKey(jwk jsonWebKey) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	var checker ecdh.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve, checker = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, checker = elliptic.P384(), ecdh.P384()
+	case "P-521":
+		curve, checker = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+	}
+
+	size := (curve.Params().BitSize + 7) / 8
+	x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+	y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if errX != nil || errY != nil || len(x) != size || len(y) != size {
+		return nil, fmt.Errorf("invalid coordinates")
+	}
+
+	// Reject points that are not on the curve
+	point := append(append([]byte{4}, x...), y...)
+	if _, err := checker.NewPublicKey(point); err != nil {
+		return nil, fmt.Errorf("invalid point: %w", err)
+	}
+	return &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
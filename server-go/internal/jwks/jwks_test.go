@@ -0,0 +1,188 @@
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testIssuer serves a JWKS whose keys can be rotated, counting fetches.
+type testIssuer struct {
+	mu      sync.Mutex
+	keys    map[string]*ecdsa.PrivateKey
+	fetches int
+	server  *httptest.Server
+}
+
+func newTestIssuer(t *testing.T, kids ...string) *testIssuer {
+	t.Helper()
+	ti := &testIssuer{}
+	ti.rotate(t, kids...)
+	ti.server = httptest.NewServer(http.HandlerFunc(ti.serve))
+	t.Cleanup(ti.server.Close)
+	return ti
+}
+
+// rotate replaces the published keys with new keys named kids.
+func (ti *testIssuer) rotate(t *testing.T, kids ...string) {
+	t.Helper()
+	keys := make(map[string]*ecdsa.PrivateKey, len(kids))
+	for _, kid := range kids {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKey: %v", err)
+		}
+		keys[kid] = key
+	}
+	ti.mu.Lock()
+	ti.keys = keys
+	ti.mu.Unlock()
+}
+
+func (ti *testIssuer) serve(w http.ResponseWriter, r *http.Request) {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	ti.fetches++
+
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	for kid, key := range ti.keys {
+		doc.Keys = append(doc.Keys, jsonWebKey{
+			Kty: "EC",
+			Kid: kid,
+			Use: "sig",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		})
+	}
+	json.NewEncoder(w).Encode(doc)
+}
+
+func (ti *testIssuer) fetchCount() int {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	return ti.fetches
+}
+
+func (ti *testIssuer) sign(t *testing.T, kid string) *jwt.Token {
+	t.Helper()
+	ti.mu.Lock()
+	key := ti.keys[kid]
+	ti.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(signed, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	return parsed
+}
+
+func TestKeyRotation(t *testing.T) {
+	issuer := newTestIssuer(t, "k1")
+	ks, err := NewKeySet(issuer.server.URL, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+
+	if _, err := ks.Key("k1"); err != nil {
+		t.Fatalf("Key(k1): %v", err)
+	}
+
+	// A token signed with the rotated-in key fetches the new set at once
+	issuer.rotate(t, "k2")
+	if _, err := ks.Key("k2"); err != nil {
+		t.Fatalf("Key(k2) after rotation: %v", err)
+	}
+	if _, err := ks.Key("k1"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Key(k1) after rotation error = %v, want %v", err, ErrUnknownKey)
+	}
+	if got := issuer.fetchCount(); got != 2 {
+		t.Errorf("fetches = %d, want 2", got)
+	}
+}
+
+// TestUnknownKidWhileStale checks a background refresh of a stale set does
+// not use up the refetch of a token naming a kid the set does not know.
+func TestUnknownKidWhileStale(t *testing.T) {
+	issuer := newTestIssuer(t, "k1")
+	ks, err := NewKeySet(issuer.server.URL, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+
+	issuer.rotate(t, "k2")
+	ks.mu.Lock()
+	ks.fetchedAt = time.Now().Add(-2 * time.Hour)
+	ks.mu.Unlock()
+
+	if _, err := ks.Key("k2"); err != nil {
+		t.Fatalf("Key(k2) with a stale set: %v", err)
+	}
+}
+
+func TestUnknownKidRateLimited(t *testing.T) {
+	issuer := newTestIssuer(t, "k1")
+	ks, err := NewKeySet(issuer.server.URL, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+
+	for _, kid := range []string{"forged-1", "forged-2", "forged-3"} {
+		if _, err := ks.Key(kid); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Key(%s) error = %v, want %v", kid, err, ErrUnknownKey)
+		}
+	}
+	// The initial load and one refetch for the first unknown kid
+	if got := issuer.fetchCount(); got != 2 {
+		t.Errorf("fetches = %d, want 2", got)
+	}
+
+	// Once the interval has passed another unknown kid may refetch
+	issuer.rotate(t, "k2")
+	ks.mu.Lock()
+	ks.lastKidFetch = time.Now().Add(-2 * minRefetchInterval)
+	ks.mu.Unlock()
+	if _, err := ks.Key("k2"); err != nil {
+		t.Fatalf("Key(k2) after the interval: %v", err)
+	}
+}
+
+func TestKeyfunc(t *testing.T) {
+	issuer := newTestIssuer(t, "k1")
+	ks, err := NewKeySet(issuer.server.URL, time.Hour)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+
+	token := issuer.sign(t, "k1")
+	if _, err := ks.Keyfunc(token); err != nil {
+		t.Errorf("Keyfunc(ES256): %v", err)
+	}
+
+	// An EC key must not verify a token claiming RSA
+	token.Method = jwt.SigningMethodRS256
+	token.Header["alg"] = "RS256"
+	if _, err := ks.Keyfunc(token); err == nil {
+		t.Error("Keyfunc accepted an EC key for RS256")
+	}
+}
@@ -0,0 +1,195 @@
+// Package metrics holds the Prometheus collectors shared by the HTTP server
+// and the worker and serves them on /metrics. Each binary only updates the
+// collectors for the work it does; the rest stay at zero.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Approval outcomes.
+const (
+	ApprovalGranted  = "granted"
+	ApprovalRejected = "rejected"
+	ApprovalTimedOut = "timed_out"
+)
+
+// registry holds this process's collectors plus Go runtime and process
+// metrics.
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route template, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route template and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	emailsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "emails_sent_total",
+		Help: "Emails accepted by the provider, by tenant and template type. Test sends are not counted.",
+	}, []string{"tenant", "template_type"})
+
+	emailsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "emails_failed_total",
+		Help: "Email send attempts that failed, by tenant and template type. Test sends are not counted.",
+	}, []string{"tenant", "template_type"})
+
+	providerLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "email_provider_request_duration_seconds",
+		Help:    "Latency of email provider API calls by operation and outcome.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"operation", "outcome"})
+
+	activityRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "temporal_activity_retries_total",
+		Help: "Activity attempts after the first, by activity.",
+	}, []string{"activity"})
+
+	approvals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "email_approvals_total",
+		Help: "Reviewer approval outcomes: granted, rejected or timed_out.",
+	}, []string{"outcome"})
+
+	workflowsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "temporal_workflows_in_flight",
+		Help: "Workflows started by this server process that have not completed, by workflow type. Workflows started elsewhere or before a restart are not counted.",
+	}, []string{"workflow"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		emailsSent, emailsFailed, providerLatency, activityRetries,
+		approvals, workflowsInFlight,
+	)
+}
+
+// Handler serves the collected metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// NewServer returns a server that exposes /metrics on addr, kept apart from
+// the public API because labels include tenant IDs.
+func NewServer(addr string) *http.Server {
+	serveMux := http.NewServeMux()
+	serveMux.Handle("/metrics", Handler())
+	return &http.Server{
+		Addr:         addr,
+		Handler:      serveMux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+}
+
+// Middleware counts and times requests by their mux route template, so
+// /api/email-tracking/{id} is one series rather than one per ID.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Inc()
+		httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// templateTypes are the template_type label values; the type comes from
+// caller metadata and tenant templates, so anything else is counted as
+// "other" to keep the label's cardinality fixed.
+var templateTypes = map[string]bool{
+	"marketing":     true,
+	"transactional": true,
+	"newsletter":    true,
+	"notification":  true,
+}
+
+// EmailSent counts an email accepted by the provider.
+func EmailSent(tenant, templateType string) {
+	emailsSent.WithLabelValues(labelOrUnknown(tenant), templateTypeLabel(templateType)).Inc()
+}
+
+// EmailFailed counts a failed send attempt.
+func EmailFailed(tenant, templateType string) {
+	emailsFailed.WithLabelValues(labelOrUnknown(tenant), templateTypeLabel(templateType)).Inc()
+}
+
+// ObserveProvider records the latency of a provider call started at start.
+func ObserveProvider(operation string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	providerLatency.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
+
+// ActivityAttempt counts a retry when attempt, Temporal's 1-based attempt
+// number, is past the first.
+func ActivityAttempt(activity string, attempt int32) {
+	if attempt > 1 {
+		activityRetries.WithLabelValues(activity).Inc()
+	}
+}
+
+// Approval counts a reviewer approval outcome.
+func Approval(outcome string) {
+	approvals.WithLabelValues(outcome).Inc()
+}
+
+// WorkflowStarted marks a workflow of the given type as in flight. The gauge
+// only covers workflows this process started and is monitoring, not every
+// open workflow in Temporal.
+func WorkflowStarted(workflow string) {
+	workflowsInFlight.WithLabelValues(workflow).Inc()
+}
+
+// WorkflowFinished marks a workflow started with WorkflowStarted as done.
+func WorkflowFinished(workflow string) {
+	workflowsInFlight.WithLabelValues(workflow).Dec()
+}
+
+func templateTypeLabel(templateType string) string {
+	if templateTypes[templateType] {
+		return templateType
+	}
+	return "other"
+}
+
+func labelOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
@@ -0,0 +1,128 @@
+package preferences
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Subscription categories a recipient can opt out of individually.
+const (
+	CategoryNewsletter     = "newsletter"
+	CategoryPromotions     = "promotions"
+	CategoryProductUpdates = "product_updates"
+)
+
+// Categories lists the categories in the order the preference page shows them.
+var Categories = []string{CategoryNewsletter, CategoryPromotions, CategoryProductUpdates}
+
+// CategoryLabels are the names shown to recipients.
+var CategoryLabels = map[string]string{
+	CategoryNewsletter:     "Newsletter",
+	CategoryPromotions:     "Promotions and offers",
+	CategoryProductUpdates: "Product updates",
+}
+
+// templateCategories maps email template types to the category they belong
+// to. Transactional and notification emails have no category and are always
+// sent.
+var templateCategories = map[string]string{
+	"newsletter": CategoryNewsletter,
+	"marketing":  CategoryPromotions,
+}
+
+// CategoryFor returns the category of an email: an explicit metadata
+// category when it names a known one, otherwise the template type's.
+func CategoryFor(templateType, category string) string {
+	if IsCategory(category) {
+		return category
+	}
+	return templateCategories[templateType]
+}
+
+func IsCategory(category string) bool {
+	_, ok := CategoryLabels[category]
+	return ok
+}
+
+// Preferences are one contact's choices within a tenant. Categories missing
+// from OptedOut are opted in.
+type Preferences struct {
+	TenantID  string          `json:"tenantId"`
+	Email     string          `json:"email"`
+	OptedOut  map[string]bool `json:"optedOut"`
+	UpdatedAt time.Time       `json:"updatedAt,omitempty"`
+}
+
+// Store holds preferences in memory, keyed by tenant and contact address.
+type Store struct {
+	mu    sync.RWMutex
+	prefs map[string]*Preferences
+}
+
+func NewStore() *Store {
+	return &Store{
+		prefs: make(map[string]*Preferences),
+	}
+}
+
+func key(tenantID, email string) string {
+	return tenantID + "|" + strings.ToLower(email)
+}
+
+// Get returns the contact's preferences; a contact who never changed them is
+// opted in to everything.
+func (s *Store) Get(tenantID, email string) Preferences {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := Preferences{TenantID: tenantID, Email: strings.ToLower(email), OptedOut: map[string]bool{}}
+	if p, ok := s.prefs[key(tenantID, email)]; ok {
+		for category, out := range p.OptedOut {
+			result.OptedOut[category] = out
+		}
+		result.UpdatedAt = p.UpdatedAt
+	}
+	return result
+}
+
+// Set replaces the contact's opt-outs. Unknown categories are ignored.
+func (s *Store) Set(tenantID, email string, optedOut map[string]bool) Preferences {
+	p := &Preferences{
+		TenantID:  tenantID,
+		Email:     strings.ToLower(email),
+		OptedOut:  map[string]bool{},
+		UpdatedAt: time.Now().UTC(),
+	}
+	for category, out := range optedOut {
+		if out && IsCategory(category) {
+			p.OptedOut[category] = true
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prefs[key(tenantID, email)] = p
+	return *p
+}
+
+// OptedOut reports whether the contact opted out of category.
+func (s *Store) OptedOut(tenantID, email, category string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.prefs[key(tenantID, email)]
+	return ok && p.OptedOut[category]
+}
+
+// Remove forgets the contact's preferences, returning them to the defaults.
+func (s *Store) Remove(tenantID, email string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(tenantID, email)
+	_, ok := s.prefs[k]
+	delete(s.prefs, k)
+	return ok
+}
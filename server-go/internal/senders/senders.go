@@ -0,0 +1,236 @@
+package senders
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusVerified = "verified"
+
+	verificationPurpose = "sender-verification"
+)
+
+var (
+	ErrNotFound    = errors.New("sender identity not found")
+	ErrDuplicate   = errors.New("sender identity already exists for this address")
+	ErrNotVerified = errors.New("sender identity is not verified")
+)
+
+// Identity is a From address registered by a tenant. It can only be used for
+// sending once ownership has been confirmed through the verification email.
+type Identity struct {
+	ID          string     `json:"id"`
+	TenantID    string     `json:"tenantId"`
+	Email       string     `json:"email"`
+	DisplayName string     `json:"displayName,omitempty"`
+	Status      string     `json:"status"`
+	IsDefault   bool       `json:"isDefault"`
+	CreatedBy   string     `json:"createdBy"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	VerifiedAt  *time.Time `json:"verifiedAt,omitempty"`
+}
+
+// Address formats the identity as an RFC 5322 address with display name.
+func (i Identity) Address() string {
+	return (&mail.Address{Name: i.DisplayName, Address: i.Email}).String()
+}
+
+// Store holds sender identities in memory, like the tracking store.
+type Store struct {
+	mu         sync.RWMutex
+	identities map[string]*Identity
+}
+
+func NewStore() *Store {
+	return &Store{
+		identities: make(map[string]*Identity),
+	}
+}
+
+// Create registers a pending identity for the tenant.
+func (s *Store) Create(tenantID, userID, email, displayName string) (Identity, error) {
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid email address %q", email)
+	}
+	if strings.ContainsAny(displayName, "\r\n") {
+		return Identity{}, fmt.Errorf("display name contains a line break")
+	}
+	normalized := strings.ToLower(addr.Address)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.identities {
+		if existing.TenantID == tenantID && existing.Email == normalized {
+			return Identity{}, ErrDuplicate
+		}
+	}
+
+	now := time.Now().UTC()
+	identity := &Identity{
+		ID:          fmt.Sprintf("snd_%d", now.UnixNano()),
+		TenantID:    tenantID,
+		Email:       normalized,
+		DisplayName: displayName,
+		Status:      StatusPending,
+		CreatedBy:   userID,
+		CreatedAt:   now,
+	}
+	s.identities[identity.ID] = identity
+	return *identity, nil
+}
+
+func (s *Store) List(tenantID string) []Identity {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []Identity
+	for _, identity := range s.identities {
+		if identity.TenantID == tenantID {
+			result = append(result, *identity)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+func (s *Store) Get(tenantID, id string) (Identity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	identity, ok := s.identities[id]
+	if !ok || identity.TenantID != tenantID {
+		return Identity{}, ErrNotFound
+	}
+	return *identity, nil
+}
+
+func (s *Store) Delete(tenantID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[id]
+	if !ok || identity.TenantID != tenantID {
+		return ErrNotFound
+	}
+	delete(s.identities, id)
+	return nil
+}
+
+// MarkVerified confirms ownership of the identity's address. The email must
+// match so a token issued before the address changed cannot verify it.
+func (s *Store) MarkVerified(id, email string) (Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[id]
+	if !ok || identity.Email != email {
+		return Identity{}, ErrNotFound
+	}
+	if identity.Status != StatusVerified {
+		now := time.Now().UTC()
+		identity.Status = StatusVerified
+		identity.VerifiedAt = &now
+	}
+	return *identity, nil
+}
+
+// SetDefault makes a verified identity the tenant's default sender.
+func (s *Store) SetDefault(tenantID, id string) (Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[id]
+	if !ok || identity.TenantID != tenantID {
+		return Identity{}, ErrNotFound
+	}
+	if identity.Status != StatusVerified {
+		return Identity{}, ErrNotVerified
+	}
+	for _, other := range s.identities {
+		if other.TenantID == tenantID {
+			other.IsDefault = false
+		}
+	}
+	identity.IsDefault = true
+	return *identity, nil
+}
+
+// Resolve picks the sender for a send: the requested identity when id is set,
+// otherwise the tenant's verified default. It returns false when the system
+// default From address should be used.
+func (s *Store) Resolve(tenantID, id string) (Identity, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if id != "" {
+		identity, ok := s.identities[id]
+		if !ok || identity.TenantID != tenantID {
+			return Identity{}, false, ErrNotFound
+		}
+		if identity.Status != StatusVerified {
+			return Identity{}, false, ErrNotVerified
+		}
+		return *identity, true, nil
+	}
+
+	for _, identity := range s.identities {
+		if identity.TenantID == tenantID && identity.IsDefault && identity.Status == StatusVerified {
+			return *identity, true, nil
+		}
+	}
+	return Identity{}, false, nil
+}
+
+type verificationClaims struct {
+	IdentityID string `json:"identityId"`
+	Email      string `json:"email"`
+	Purpose    string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// IssueVerificationToken signs the token embedded in the confirmation link.
+func IssueVerificationToken(secret string, identity Identity, ttl time.Duration) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, verificationClaims{
+		IdentityID: identity.ID,
+		Email:      identity.Email,
+		Purpose:    verificationPurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+	return token.SignedString([]byte(secret))
+}
+
+// ParseVerificationToken returns the identity ID and email a confirmation
+// token was issued for.
+func ParseVerificationToken(secret, tokenString string) (string, string, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &verificationClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	claims, ok := token.Claims.(*verificationClaims)
+	if !ok || !token.Valid || claims.Purpose != verificationPurpose {
+		return "", "", fmt.Errorf("invalid verification token")
+	}
+	return claims.IdentityID, claims.Email, nil
+}
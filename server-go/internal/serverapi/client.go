@@ -0,0 +1,119 @@
+package serverapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"email-tracking-server/internal/suppression"
+)
+
+// TokenHeader carries the shared secret on worker-to-server requests.
+const TokenHeader = "X-Internal-Token"
+
+// Client calls the HTTP server's internal endpoints from the worker, for
+// state that lives in the server process.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Enabled reports whether the client is configured. Without a token the
+// server rejects internal requests, so callers skip them.
+func (c *Client) Enabled() bool {
+	return c != nil && c.baseURL != "" && c.token != ""
+}
+
+// RecordEvent appends an event to a tracking entry's timeline.
+func (c *Client) RecordEvent(ctx context.Context, entryID, eventType string, details map[string]interface{}) error {
+	body := map[string]interface{}{
+		"type":    eventType,
+		"details": details,
+	}
+	return c.post(ctx, "/internal/email-tracking/"+url.PathEscape(entryID)+"/events", body, nil)
+}
+
+// CheckSuppressions returns the suppression entries, keyed by normalized
+// address, for those of emails the tenant may not send to.
+func (c *Client) CheckSuppressions(ctx context.Context, tenantID string, emails []string) (map[string]suppression.Entry, error) {
+	var resp struct {
+		Suppressed []suppression.Entry `json:"suppressed"`
+	}
+	body := map[string]interface{}{
+		"tenantId": tenantID,
+		"emails":   emails,
+	}
+	if err := c.post(ctx, "/internal/suppressions/check", body, &resp); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]suppression.Entry, len(resp.Suppressed))
+	for _, entry := range resp.Suppressed {
+		result[entry.Email] = entry
+	}
+	return result, nil
+}
+
+// OptedOut reports whether the contact opted out of category in the
+// tenant's preference center.
+func (c *Client) OptedOut(ctx context.Context, tenantID, email, category string) (bool, error) {
+	var resp struct {
+		OptedOut bool `json:"optedOut"`
+	}
+	body := map[string]interface{}{
+		"tenantId": tenantID,
+		"email":    email,
+		"category": category,
+	}
+	if err := c.post(ctx, "/internal/preferences/check", body, &resp); err != nil {
+		return false, err
+	}
+	return resp.OptedOut, nil
+}
+
+// post sends payload as JSON and decodes the response into out when it is
+// not nil.
+func (c *Client) post(ctx context.Context, path string, payload interface{}, out interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TokenHeader, c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("request to %s failed: %s: %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response from %s: %w", path, err)
+		}
+	}
+	return nil
+}
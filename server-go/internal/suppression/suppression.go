@@ -0,0 +1,171 @@
+package suppression
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reasons an address is suppressed.
+const (
+	ReasonBounce      = "bounce"
+	ReasonComplaint   = "complaint"
+	ReasonUnsubscribe = "unsubscribe"
+	ReasonManual      = "manual"
+)
+
+// GlobalTenant is the tenant ID of global blocks, which apply to every
+// tenant. Hard bounces are global: the address does not accept mail at all.
+const GlobalTenant = ""
+
+var ErrNotFound = errors.New("address is not suppressed")
+
+// Entry is one suppressed address.
+type Entry struct {
+	TenantID  string    `json:"tenantId,omitempty"`
+	Email     string    `json:"email"`
+	Reason    string    `json:"reason"`
+	Global    bool      `json:"global,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Store holds suppressed addresses in memory, keyed by tenant and address.
+type Store struct {
+	mu      sync.RWMutex
+	entries map[string]*Entry
+}
+
+func NewStore() *Store {
+	return &Store{
+		entries: make(map[string]*Entry),
+	}
+}
+
+// Normalize returns the lowercased bare address.
+func Normalize(email string) (string, error) {
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		return "", fmt.Errorf("invalid email address %q", email)
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+func key(tenantID, email string) string {
+	return tenantID + "|" + email
+}
+
+// Add suppresses email for the tenant, or globally for GlobalTenant. Adding
+// an address that is already suppressed keeps the original entry.
+func (s *Store) Add(tenantID, email, reason, note, createdBy string) (Entry, error) {
+	normalized, err := Normalize(email)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(tenantID, normalized)
+	if existing, ok := s.entries[k]; ok {
+		return *existing, nil
+	}
+	entry := &Entry{
+		TenantID:  tenantID,
+		Email:     normalized,
+		Reason:    reason,
+		Global:    tenantID == GlobalTenant,
+		Note:      note,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now().UTC(),
+	}
+	s.entries[k] = entry
+	return *entry, nil
+}
+
+// List returns the tenant's own entries, oldest first. Global blocks come
+// from bounces of every tenant's sends, so they are only applied by Check and
+// never listed to a tenant.
+func (s *Store) List(tenantID string) []Entry {
+	if tenantID == GlobalTenant {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []Entry
+	for _, entry := range s.entries {
+		if entry.TenantID == tenantID {
+			result = append(result, *entry)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// Remove lifts a tenant suppression. Global blocks cannot be removed through
+// a tenant.
+func (s *Store) Remove(tenantID, email string) error {
+	normalized, err := Normalize(email)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := key(tenantID, normalized)
+	if _, ok := s.entries[k]; !ok || tenantID == GlobalTenant {
+		return ErrNotFound
+	}
+	delete(s.entries, k)
+	return nil
+}
+
+// Redact replaces the note on the tenant's suppression of email, which may
+// describe the person, with note. The suppression itself stays so an erased
+// address cannot be mailed again. It reports whether there was an entry.
+func (s *Store) Redact(tenantID, email, note string) bool {
+	normalized, err := Normalize(email)
+	if err != nil || tenantID == GlobalTenant {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key(tenantID, normalized)]
+	if !ok {
+		return false
+	}
+	entry.Note = note
+	return true
+}
+
+// Check returns the entry suppressing email for the tenant, if any. Tenant
+// entries take precedence over global blocks.
+func (s *Store) Check(tenantID, email string) (Entry, bool) {
+	normalized, err := Normalize(email)
+	if err != nil {
+		return Entry{}, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if entry, ok := s.entries[key(tenantID, normalized)]; ok {
+		return *entry, true
+	}
+	if entry, ok := s.entries[key(GlobalTenant, normalized)]; ok {
+		return *entry, true
+	}
+	return Entry{}, false
+}
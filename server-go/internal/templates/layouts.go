@@ -0,0 +1,116 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+)
+
+// FieldContent is the merge field through which a tenant template version
+// receives the rendered campaign body.
+const FieldContent = "Content"
+
+// LayoutData is passed to a layout when wrapping rendered content.
+type LayoutData struct {
+	Content template.HTML
+}
+
+var builtinLayouts = map[string]*template.Template{
+	"marketing": template.Must(template.New("marketing").Parse(`
+			<html>
+			<body style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+				<div style="background: linear-gradient(135deg, #667eea 0%, #764ba2 100%); padding: 20px; text-align: center;">
+					<h1 style="color: white; margin: 0;">📧 Marketing Email</h1>
+				</div>
+				<div style="padding: 30px; background: #f9f9f9;">
+					<div style="background: white; padding: 20px; border-radius: 8px; box-shadow: 0 2px 4px rgba(0,0,0,0.1);">
+						{{.Content}}
+					</div>
+				</div>
+				<div style="background: #333; color: white; padding: 15px; text-align: center; font-size: 12px;">
+					Sent via Authentik Email Campaign System
+				</div>
+			</body>
+			</html>
+		`)),
+	"transactional": template.Must(template.New("transactional").Parse(`
+			<html>
+			<body style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+				<div style="border-left: 4px solid #4CAF50; padding: 20px;">
+					<h2 style="color: #333; margin-top: 0;">🔄 Transaction Notification</h2>
+					<div style="line-height: 1.6; color: #666;">
+						{{.Content}}
+					</div>
+				</div>
+			</body>
+			</html>
+		`)),
+	"newsletter": template.Must(template.New("newsletter").Parse(`
+			<html>
+			<body style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+				<div style="background: #2196F3; color: white; padding: 20px; text-align: center;">
+					<h1 style="margin: 0;">📰 Newsletter</h1>
+				</div>
+				<div style="padding: 20px; background: white;">
+					{{.Content}}
+				</div>
+			</body>
+			</html>
+		`)),
+	"notification": template.Must(template.New("notification").Parse(`
+			<html>
+			<body style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+				<div style="background: #FF9800; color: white; padding: 15px; border-radius: 4px;">
+					<h3 style="margin: 0;">🔔 Notification</h3>
+				</div>
+				<div style="padding: 20px; border: 1px solid #ddd; border-top: none;">
+					{{.Content}}
+				</div>
+			</body>
+			</html>
+		`)),
+}
+
+var defaultLayout = template.Must(template.New("default").Parse(`
+			<html>
+			<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333;">
+				{{.Content}}
+			</body>
+			</html>
+		`))
+
+// RenderLayout wraps already-rendered content in the built-in layout for the
+// given template type, falling back to a plain shell for unknown types.
+func RenderLayout(templateType string, content string) (string, error) {
+	layout, ok := builtinLayouts[templateType]
+	if !ok {
+		layout = defaultLayout
+	}
+
+	var buf bytes.Buffer
+	if err := layout.Execute(&buf, LayoutData{Content: template.HTML(content)}); err != nil {
+		return "", fmt.Errorf("failed to render %s layout: %w", layout.Name(), err)
+	}
+	return buf.String(), nil
+}
+
+// RenderVersion renders a tenant template version around already-rendered
+// content, in place of the built-in layouts.
+func RenderVersion(v Version, content string, data MergeData) (string, error) {
+	return Render(v.HTML, withContent(data, content))
+}
+
+// ValidateVersion checks that every merge field the version references is
+// available in data.
+func ValidateVersion(v Version, data MergeData) error {
+	return Validate(v.HTML, withContent(data, ""))
+}
+
+func withContent(data MergeData, content string) MergeData {
+	merged := MergeData{}
+	for k, v := range data {
+		merged[k] = v
+	}
+	merged[FieldContent] = template.HTML(content)
+	return merged
+}
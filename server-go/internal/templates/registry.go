@@ -0,0 +1,202 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrVersionNotFound  = errors.New("template version not found")
+	ErrNotPublished     = errors.New("template has no published version")
+)
+
+// Template is a tenant-owned email design. Versions are immutable once
+// created; PublishedVersion points at the version used when a send does not
+// pin one explicitly.
+type Template struct {
+	ID               string    `json:"id"`
+	TenantID         string    `json:"tenantId"`
+	Name             string    `json:"name"`
+	Type             string    `json:"type"`
+	PublishedVersion int       `json:"publishedVersion,omitempty"`
+	Versions         []Version `json:"versions"`
+	CreatedBy        string    `json:"createdBy"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// Version is a single immutable revision of a template. HTML is a full email
+// document rendered with the recipient's merge data; the campaign body is
+// available to it as {{.Content}}.
+type Version struct {
+	TemplateID string    `json:"templateId"`
+	Number     int       `json:"number"`
+	Subject    string    `json:"subject,omitempty"`
+	HTML       string    `json:"html"`
+	CreatedBy  string    `json:"createdBy"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Registry stores templates per tenant. Like the tracking store it is held in
+// memory for now.
+type Registry struct {
+	mu        sync.RWMutex
+	templates map[string]*Template
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		templates: make(map[string]*Template),
+	}
+}
+
+// Create stores a new template with html as version 1.
+func (r *Registry) Create(tenantID, userID, name, templateType, subject, html string) (Template, error) {
+	if _, err := Parse(name, html); err != nil {
+		return Template{}, &ValidationError{ParseError: err}
+	}
+	if _, err := Parse(name+" subject", subject); err != nil {
+		return Template{}, &ValidationError{ParseError: err}
+	}
+
+	now := time.Now().UTC()
+	t := &Template{
+		ID:        fmt.Sprintf("tpl_%d", now.UnixNano()),
+		TenantID:  tenantID,
+		Name:      name,
+		Type:      templateType,
+		CreatedBy: userID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	t.Versions = []Version{{
+		TemplateID: t.ID,
+		Number:     1,
+		Subject:    subject,
+		HTML:       html,
+		CreatedBy:  userID,
+		CreatedAt:  now,
+	}}
+
+	r.mu.Lock()
+	r.templates[t.ID] = t
+	r.mu.Unlock()
+
+	return *t, nil
+}
+
+// List returns the tenant's templates ordered by creation time.
+func (r *Registry) List(tenantID string) []Template {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []Template
+	for _, t := range r.templates {
+		if t.TenantID == tenantID {
+			result = append(result, *t)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+func (r *Registry) Get(tenantID, id string) (Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.templates[id]
+	if !ok || t.TenantID != tenantID {
+		return Template{}, ErrTemplateNotFound
+	}
+	return *t, nil
+}
+
+// AddVersion appends a new immutable version. It does not change the
+// published pointer.
+func (r *Registry) AddVersion(tenantID, id, userID, subject, html string) (Version, error) {
+	if _, err := Parse(id, html); err != nil {
+		return Version{}, &ValidationError{ParseError: err}
+	}
+	if _, err := Parse(id+" subject", subject); err != nil {
+		return Version{}, &ValidationError{ParseError: err}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.templates[id]
+	if !ok || t.TenantID != tenantID {
+		return Version{}, ErrTemplateNotFound
+	}
+
+	now := time.Now().UTC()
+	v := Version{
+		TemplateID: t.ID,
+		Number:     len(t.Versions) + 1,
+		Subject:    subject,
+		HTML:       html,
+		CreatedBy:  userID,
+		CreatedAt:  now,
+	}
+	t.Versions = append(t.Versions, v)
+	t.UpdatedAt = now
+	return v, nil
+}
+
+// Publish moves the published pointer to an existing version.
+func (r *Registry) Publish(tenantID, id string, version int) (Template, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.templates[id]
+	if !ok || t.TenantID != tenantID {
+		return Template{}, ErrTemplateNotFound
+	}
+	if version < 1 || version > len(t.Versions) {
+		return Template{}, ErrVersionNotFound
+	}
+
+	t.PublishedVersion = version
+	t.UpdatedAt = time.Now().UTC()
+	return *t, nil
+}
+
+func (r *Registry) Delete(tenantID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.templates[id]
+	if !ok || t.TenantID != tenantID {
+		return ErrTemplateNotFound
+	}
+	delete(r.templates, id)
+	return nil
+}
+
+// Resolve returns the requested version, or the published version when
+// version is 0.
+func (r *Registry) Resolve(tenantID, id string, version int) (Template, Version, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.templates[id]
+	if !ok || t.TenantID != tenantID {
+		return Template{}, Version{}, ErrTemplateNotFound
+	}
+	if version == 0 {
+		if t.PublishedVersion == 0 {
+			return Template{}, Version{}, ErrNotPublished
+		}
+		version = t.PublishedVersion
+	}
+	if version < 1 || version > len(t.Versions) {
+		return Template{}, Version{}, ErrVersionNotFound
+	}
+	return *t, t.Versions[version-1], nil
+}
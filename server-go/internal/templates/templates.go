@@ -0,0 +1,208 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+// System merge fields are filled in by the send pipeline for every recipient,
+// so templates may reference them without the caller supplying a value.
+const (
+	FieldEmail          = "Email"
+	FieldUnsubscribeURL = "UnsubscribeURL"
+	FieldPreferencesURL = "PreferencesURL"
+)
+
+var systemFields = map[string]bool{
+	FieldEmail:          true,
+	FieldUnsubscribeURL: true,
+	FieldPreferencesURL: true,
+}
+
+// Metadata keys read from EmailData.Metadata when building merge data.
+const (
+	MetadataMergeFields    = "mergeFields"
+	MetadataUnsubscribeURL = "unsubscribeUrl"
+	MetadataPreferencesURL = "preferencesUrl"
+)
+
+// MergeData holds the per-recipient values available to a template, keyed by
+// merge field name (e.g. "FirstName" for {{.FirstName}}).
+type MergeData map[string]interface{}
+
+// NewMergeData builds the merge data for a single recipient from the
+// caller-supplied fields plus the system fields.
+func NewMergeData(recipient string, fields map[string]interface{}, unsubscribeURL, preferencesURL string) MergeData {
+	data := MergeData{}
+	for k, v := range fields {
+		data[k] = v
+	}
+	data[FieldEmail] = recipient
+	data[FieldUnsubscribeURL] = unsubscribeURL
+	data[FieldPreferencesURL] = preferencesURL
+	return data
+}
+
+// MergeDataFromMetadata builds merge data from tracking metadata. Custom and
+// contact fields are read from the "mergeFields" object.
+func MergeDataFromMetadata(recipient string, metadata map[string]interface{}) MergeData {
+	fields, _ := metadata[MetadataMergeFields].(map[string]interface{})
+	unsubscribeURL, _ := metadata[MetadataUnsubscribeURL].(string)
+	preferencesURL, _ := metadata[MetadataPreferencesURL].(string)
+	return NewMergeData(recipient, fields, unsubscribeURL, preferencesURL)
+}
+
+// ValidationError describes why a template cannot be rendered with the merge
+// data it was given.
+type ValidationError struct {
+	ParseError    error
+	MissingFields []string
+}
+
+func (e *ValidationError) Error() string {
+	if e.ParseError != nil {
+		return fmt.Sprintf("invalid template: %v", e.ParseError)
+	}
+	return fmt.Sprintf("template references undefined merge fields: %s", strings.Join(e.MissingFields, ", "))
+}
+
+// Parse compiles a content template. Missing keys are treated as errors so a
+// recipient never receives "<no value>" in place of a merge field.
+func Parse(name, src string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(src)
+}
+
+// Fields returns the top-level merge fields referenced by the template, sorted
+// and de-duplicated. Fields used inside range/with blocks are relative to a
+// different dot and are not reported.
+func Fields(t *template.Template) []string {
+	seen := map[string]bool{}
+	if t.Tree != nil {
+		collectFields(t.Tree.Root, seen)
+	}
+	fields := make([]string, 0, len(seen))
+	for f := range seen {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func collectFields(node parse.Node, seen map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectFields(child, seen)
+		}
+	case *parse.ActionNode:
+		collectFields(n.Pipe, seen)
+	case *parse.IfNode:
+		collectFields(n.Pipe, seen)
+		collectFields(n.List, seen)
+		collectFields(n.ElseList, seen)
+	case *parse.RangeNode:
+		collectFields(n.Pipe, seen)
+		collectFields(n.ElseList, seen)
+	case *parse.WithNode:
+		collectFields(n.Pipe, seen)
+		collectFields(n.ElseList, seen)
+	case *parse.TemplateNode:
+		collectFields(n.Pipe, seen)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectFields(cmd, seen)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectFields(arg, seen)
+		}
+	case *parse.FieldNode:
+		if len(n.Ident) > 0 {
+			seen[n.Ident[0]] = true
+		}
+	}
+}
+
+// Validate checks that src parses and that every merge field it references is
+// either a system field or present in data. It returns a *ValidationError.
+func Validate(src string, data MergeData) error {
+	_, err := parseAndCheck(src, data)
+	return err
+}
+
+// Render executes src against data. Merge values are escaped according to the
+// HTML context they appear in.
+func Render(src string, data MergeData) (string, error) {
+	t, err := parseAndCheck(src, data)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// RenderText executes a plain-text template against data without HTML
+// escaping, for text/plain parts supplied by the caller.
+func RenderText(src string, data MergeData) (string, error) {
+	if _, err := parseAndCheck(src, data); err != nil {
+		return "", err
+	}
+
+	t, err := texttemplate.New("text").Option("missingkey=error").Parse(src)
+	if err != nil {
+		return "", &ValidationError{ParseError: err}
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render text template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// RenderSubject executes a subject line against data like RenderText, then
+// folds line breaks from merge values into spaces so the result is a single
+// header line.
+func RenderSubject(src string, data MergeData) (string, error) {
+	subject, err := RenderText(src, data)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(subject), " "), nil
+}
+
+func parseAndCheck(src string, data MergeData) (*template.Template, error) {
+	t, err := Parse("content", src)
+	if err != nil {
+		return nil, &ValidationError{ParseError: err}
+	}
+
+	var missing []string
+	for _, field := range Fields(t) {
+		if systemFields[field] {
+			continue
+		}
+		if _, ok := data[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &ValidationError{MissingFields: missing}
+	}
+	return t, nil
+}
@@ -0,0 +1,147 @@
+package tracking
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	PurposeOpen        = "open"
+	PurposeClick       = "click"
+	PurposeUnsubscribe = "unsubscribe"
+	PurposePreferences = "preferences"
+
+	// dedupeWindow collapses repeated loads of the same pixel by the same
+	// client, which mail clients do when a message is re-rendered.
+	dedupeWindow = time.Minute
+
+	// prefetchWindow is how soon after sending an open or click is
+	// attributed to a security scanner rather than a person.
+	prefetchWindow = 5 * time.Second
+)
+
+// Pixel is a transparent 1x1 GIF.
+var Pixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// machineAgents are user agent fragments of link scanners, crawlers and
+// scripted clients. Matching is case-insensitive.
+var machineAgents = []string{
+	"bot", "crawler", "spider", "curl", "wget", "python-requests", "go-http-client",
+	"headlesschrome", "phantomjs", "barracuda", "mimecast", "proofpoint", "symantec",
+	"trendmicro", "forcepoint", "fortiguard", "sophos",
+}
+
+// Claims identify the tracking entry, tenant and recipient a tracked email
+// was sent to. IssuedAt is the send time. Click tokens also carry the link's position
+// in the email and its destination, so the redirect target cannot be altered
+// without invalidating the signature.
+type Claims struct {
+	EntryID   string `json:"eid"`
+	TenantID  string `json:"tid,omitempty"`
+	Recipient string `json:"rcpt"`
+	Purpose   string `json:"purpose"`
+	Link      int    `json:"link,omitempty"`
+	URL       string `json:"url,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Sign issues a token for claims, stamping the send time. Tracking tokens do
+// not expire; emails are opened long after they are sent.
+func Sign(secret string, claims Claims) (string, error) {
+	claims.IssuedAt = jwt.NewNumericDate(time.Now())
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
+
+// Parse verifies a token and checks it was issued for purpose, so a token
+// taken from one tracking URL cannot be replayed against another endpoint.
+func Parse(secret, purpose, tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid || claims.Purpose != purpose || claims.EntryID == "" {
+		return nil, fmt.Errorf("invalid tracking token")
+	}
+	if purpose == PurposeClick && !Redirectable(claims.URL) {
+		return nil, fmt.Errorf("invalid tracking token destination")
+	}
+	return claims, nil
+}
+
+// Redirectable reports whether a link can be routed through the click
+// redirect. Only absolute http(s) URLs are rewritten or followed.
+func Redirectable(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// IsMachine reports whether a request for a tracking URL was most likely made
+// by software rather than the recipient: known scanner user agents, explicit
+// prefetches, or a fetch within seconds of the email being sent.
+func IsMachine(r *http.Request, sentAt time.Time) bool {
+	ua := strings.ToLower(r.UserAgent())
+	if ua == "" {
+		return true
+	}
+	for _, agent := range machineAgents {
+		if strings.Contains(ua, agent) {
+			return true
+		}
+	}
+	for _, header := range []string{"Purpose", "Sec-Purpose", "X-Moz", "X-Purpose"} {
+		if strings.Contains(strings.ToLower(r.Header.Get(header)), "prefetch") {
+			return true
+		}
+	}
+	return !sentAt.IsZero() && time.Since(sentAt) < prefetchWindow
+}
+
+// Deduper drops repeat hits from the same client within dedupeWindow.
+type Deduper struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func NewDeduper() *Deduper {
+	return &Deduper{
+		seen: make(map[string]time.Time),
+	}
+}
+
+// Seen records a hit for key and reports whether one was already recorded
+// within the window.
+func (d *Deduper) Seen(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	last, ok := d.seen[key]
+	d.seen[key] = now
+	if len(d.seen) > 10000 {
+		for k, t := range d.seen {
+			if now.Sub(t) > dedupeWindow {
+				delete(d.seen, k)
+			}
+		}
+	}
+	return ok && now.Sub(last) < dedupeWindow
+}
@@ -0,0 +1,129 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// timestampTolerance bounds how old or far in the future a signed
+	// delivery may be, limiting replay of captured requests.
+	timestampTolerance = 5 * time.Minute
+
+	// replayRetention covers the provider's retry schedule, which spans
+	// several days.
+	replayRetention = 7 * 24 * time.Hour
+)
+
+var (
+	ErrMissingHeaders   = errors.New("missing webhook signature headers")
+	ErrInvalidTimestamp = errors.New("webhook timestamp outside tolerance")
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+)
+
+// Verifier checks Svix-style signatures, which Resend uses for its webhooks:
+// an HMAC-SHA256 over "<svix-id>.<svix-timestamp>.<body>" keyed with the
+// endpoint secret.
+type Verifier struct {
+	key []byte
+}
+
+// NewVerifier accepts the endpoint secret as shown by the provider, with or
+// without its "whsec_" prefix.
+func NewVerifier(secret string) (*Verifier, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook secret: %w", err)
+	}
+	return &Verifier{key: key}, nil
+}
+
+// Verify returns the delivery ID when the signature on body is valid.
+func (v *Verifier) Verify(header http.Header, body []byte) (string, error) {
+	id := header.Get("svix-id")
+	timestamp := header.Get("svix-timestamp")
+	signatures := header.Get("svix-signature")
+	if id == "" || timestamp == "" || signatures == "" {
+		return "", ErrMissingHeaders
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", ErrInvalidTimestamp
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > timestampTolerance || age < -timestampTolerance {
+		return "", ErrInvalidTimestamp
+	}
+
+	mac := hmac.New(sha256.New, v.key)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	// The header holds space-separated "v1,<base64>" entries, one per active
+	// secret during rotation
+	for _, sig := range strings.Fields(signatures) {
+		version, encoded, ok := strings.Cut(sig, ",")
+		if !ok || version != "v1" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err == nil && hmac.Equal(decoded, expected) {
+			return id, nil
+		}
+	}
+	return "", ErrInvalidSignature
+}
+
+// ReplayCache remembers processed delivery IDs so retried or replayed
+// webhooks are applied once.
+type ReplayCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func NewReplayCache() *ReplayCache {
+	return &ReplayCache{
+		seen: make(map[string]time.Time),
+	}
+}
+
+// Add claims id for processing and reports whether this is the first time
+// it was seen, checking and recording under one lock so concurrent
+// deliveries of the same webhook are applied once. A delivery that cannot
+// be applied must be released with Remove so the provider's retry is
+// processed. Entries past the retention window are dropped once the cache
+// grows large.
+func (c *ReplayCache) Add(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := c.seen[id]; ok {
+		return false
+	}
+	c.seen[id] = now
+	if len(c.seen) > 10000 {
+		for k, t := range c.seen {
+			if now.Sub(t) > replayRetention {
+				delete(c.seen, k)
+			}
+		}
+	}
+	return true
+}
+
+// Remove releases an id claimed with Add.
+func (c *ReplayCache) Remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.seen, id)
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"email-tracking-server/internal/activities"
+	"email-tracking-server/internal/events"
 
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
@@ -35,6 +36,10 @@ func EmailWorkflow(ctx workflow.Context, emailData activities.EmailData) (*activ
 		"max_attempts", retryPolicy.MaximumAttempts,
 		"retry_interval", retryPolicy.InitialInterval)
 
+	if workflow.GetVersion(ctx, recordEventsChange, workflow.DefaultVersion, 1) == 1 {
+		recordEvent(ctx, emailData.ID, events.TypeSending, nil)
+	}
+
 	var result activities.SendEmailResult
 	err := workflow.ExecuteActivity(ctx, "SendEmail", emailData).Get(ctx, &result)
 
@@ -125,6 +130,10 @@ func ScheduledEmailWorkflow(ctx workflow.Context, scheduledAt time.Time, emailDa
 
 	logger.Info("Executing scheduled send email activity", "email_id", emailData.EmailID)
 
+	if workflow.GetVersion(ctx, recordEventsChange, workflow.DefaultVersion, 1) == 1 {
+		recordEvent(ctx, emailData.ID, events.TypeSending, nil)
+	}
+
 	var result activities.SendEmailResult
 	err := workflow.ExecuteActivity(ctx, "SendEmail", emailData).Get(ctx, &result)
 
@@ -0,0 +1,33 @@
+package workflows
+
+import (
+	"time"
+
+	"email-tracking-server/internal/activities"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// recordEventsChange is the GetVersion change ID guarding the recordEvent
+// calls. Histories of workflows started before the timeline existed have no
+// RecordEmailEvent activities, so replaying them must skip the calls.
+const recordEventsChange = "record-events"
+
+// recordEvent adds an event to the tracking entry's timeline. The timeline is
+// informational, so a failure is logged and never fails the workflow.
+func recordEvent(ctx workflow.Context, entryID, eventType string, details map[string]interface{}) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    5 * time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumAttempts:    3,
+		},
+	})
+
+	event := activities.EmailEvent{EntryID: entryID, Type: eventType, Details: details}
+	if err := workflow.ExecuteActivity(ctx, "RecordEmailEvent", event).Get(ctx, nil); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to record email event", "entry_id", entryID, "type", eventType, "error", err)
+	}
+}
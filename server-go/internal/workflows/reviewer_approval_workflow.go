@@ -4,19 +4,29 @@ import (
     "time"
 
     "email-tracking-server/internal/activities"
+    "email-tracking-server/internal/approval"
+    "email-tracking-server/internal/events"
 
     "go.temporal.io/sdk/temporal"
     "go.temporal.io/sdk/workflow"
 )
 
+// approvalTimeoutChange is the GetVersion change ID guarding the approval
+// timeout taken from the workflow input instead of a fixed 7 days.
+const approvalTimeoutChange = "approval-timeout-input"
+
 // ReviewerApprovalEmailWorkflow waits for an external approval signal before sending the email.
-// Signal name: "approval" with payload string value "approve".
+// Signal name: "approval" with payload string value "approve" or "reject".
 func ReviewerApprovalEmailWorkflow(ctx workflow.Context, emailData activities.EmailData) (*activities.SendEmailResult, error) {
 	logger := workflow.GetLogger(ctx)
 	logger.Info("Starting reviewer approval email workflow", "email_id", emailData.EmailID)
 
-	// Default: wait up to 7 days for approval
-	approvalTimeout := 7 * 24 * time.Hour
+	// Wait as long as the approval links stay valid. Workflows started before
+	// the timeout was part of the input wait the old fixed 7 days.
+	approvalTimeout := approval.DefaultTTL
+	if workflow.GetVersion(ctx, approvalTimeoutChange, workflow.DefaultVersion, 1) == 1 && emailData.ApprovalTimeout > 0 {
+		approvalTimeout = emailData.ApprovalTimeout
+	}
 
     // First activity: send reviewer notification email (with retry policy)
     retryPolicy := &temporal.RetryPolicy{
@@ -40,10 +50,15 @@ func ReviewerApprovalEmailWorkflow(ctx workflow.Context, emailData activities.Em
         // Continue workflow even if notification fails - reviewer can still approve via UI
     } else {
         logger.Info("Reviewer notification email sent", "status", notificationResult.Status)
+        if notificationResult.Status == "reviewer_notification_sent" {
+            if workflow.GetVersion(ctx, recordEventsChange, workflow.DefaultVersion, 1) == 1 {
+                recordEvent(ctx, emailData.ID, events.TypeReviewerNotified, map[string]interface{}{"resendId": notificationResult.ResendID})
+            }
+        }
     }
 
 	approvalChan := workflow.GetSignalChannel(ctx, "approval")
-	var approved bool
+	var approved, rejected bool
 
 	// Selector to wait either for approval signal or timeout
 	selector := workflow.NewSelector(ctx)
@@ -52,9 +67,12 @@ func ReviewerApprovalEmailWorkflow(ctx workflow.Context, emailData activities.Em
 	selector.AddReceive(approvalChan, func(c workflow.ReceiveChannel, more bool) {
 		var signalPayload string
 		c.Receive(ctx, &signalPayload)
-		if signalPayload == "approve" {
+		if signalPayload == approval.ActionApprove {
 			approved = true
 			logger.Info("Received approval signal", "email_id", emailData.EmailID)
+		} else if signalPayload == approval.ActionReject {
+			rejected = true
+			logger.Info("Received rejection signal", "email_id", emailData.EmailID)
 		} else {
 			logger.Info("Received non-approve signal, ignoring", "payload", signalPayload)
 		}
@@ -70,8 +88,16 @@ func ReviewerApprovalEmailWorkflow(ctx workflow.Context, emailData activities.Em
 	// Block until one of the above occurs
 	selector.Select(ctx)
 
+	if rejected {
+		return &activities.SendEmailResult{
+			EmailID: emailData.EmailID,
+			Status:  "rejected",
+			SentAt:  workflow.Now(ctx),
+			Error:   "rejected by reviewer",
+		}, nil
+	}
 	if !approved {
-		// Do not send email; mark as rejected/timeout via result status
+		// Do not send email; mark as timeout via result status
 		now := workflow.Now(ctx)
 		return &activities.SendEmailResult{
 			EmailID: emailData.EmailID,
@@ -87,6 +113,9 @@ func ReviewerApprovalEmailWorkflow(ctx workflow.Context, emailData activities.Em
 		StartToCloseTimeout: 2 * time.Minute,
 	}
 	ctx = workflow.WithActivityOptions(ctx, ao)
+	if workflow.GetVersion(ctx, recordEventsChange, workflow.DefaultVersion, 1) == 1 {
+		recordEvent(ctx, emailData.ID, events.TypeSending, nil)
+	}
 	var result activities.SendEmailResult
 	err = workflow.ExecuteActivity(ctx, "SendEmail", emailData).Get(ctx, &result)
 	if err != nil {
@@ -0,0 +1,43 @@
+package workflows
+
+import (
+	"time"
+
+	"email-tracking-server/internal/activities"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// SenderVerificationWorkflow sends the confirmation email for a newly
+// registered sender identity.
+func SenderVerificationWorkflow(ctx workflow.Context, data activities.SenderVerificationData) (*activities.SendEmailResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting sender verification workflow", "identity_id", data.IdentityID)
+
+	activityOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: 2 * time.Minute,
+		HeartbeatTimeout:    30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Minute,
+			BackoffCoefficient: 1.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+
+	var result activities.SendEmailResult
+	if err := workflow.ExecuteActivity(ctx, "SendSenderVerificationEmail", data).Get(ctx, &result); err != nil {
+		logger.Error("Sender verification email failed", "identity_id", data.IdentityID, "error", err)
+		return &activities.SendEmailResult{
+			EmailID: data.IdentityID,
+			Status:  "failed",
+			SentAt:  workflow.Now(ctx),
+			Error:   err.Error(),
+		}, err
+	}
+
+	logger.Info("Sender verification workflow completed", "identity_id", data.IdentityID, "resend_id", result.ResendID)
+	return &result, nil
+}
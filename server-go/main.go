@@ -148,7 +148,6 @@ func (s *Server) jwtMiddleware(next http.Handler) http.Handler {
 		}
 
 		log.Printf("🔍 [JWT] Token received (length: %d)", len(tokenString))
-		log.Printf("🔍 [JWT] Token preview: %s...", tokenString[:min(50, len(tokenString))])
 
 		token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 			log.Printf("🔍 [JWT] Token algorithm: %v", token.Header["alg"])
@@ -156,13 +155,11 @@ func (s *Server) jwtMiddleware(next http.Handler) http.Handler {
 				log.Printf("❌ [JWT] Unexpected signing method: %v", token.Header["alg"])
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
-			log.Printf("🔍 [JWT] Using JWT secret (length: %d)", len(s.jwtSecret))
 			return []byte(s.jwtSecret), nil
 		})
 
 		if err != nil {
 			log.Printf("❌ [JWT] Token validation error: %v", err)
-			log.Printf("🔍 [JWT] JWT secret being used: %s...", s.jwtSecret[:min(20, len(s.jwtSecret))])
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
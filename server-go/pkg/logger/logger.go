@@ -10,7 +10,33 @@ type Logger struct {
 	*slog.Logger
 }
 
+// traceIDKey is the context key of the trace ID. It lives here rather than
+// with the HTTP middleware so the logger does not depend on the services
+// that set it.
+type traceIDKey struct{}
+
+// ContextWithTraceID returns a context whose log lines carry id as trace_id.
+func ContextWithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceIDFromContext returns the trace ID stored with ContextWithTraceID, or
+// "" outside an HTTP request.
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// New creates a logger writing to stdout. JSON output is redacted with the
+// default sensitive keys and an unkeyed hash.
 func New(level string, format string) *Logger {
+	return NewWithRedaction(level, format, format == "json", nil, "")
+}
+
+// NewWithRedaction creates a logger that, when redact is set, masks email
+// addresses, tokens and the values of sensitiveKeys in addition to the
+// defaults, hashing them with hashKey.
+func NewWithRedaction(level string, format string, redact bool, sensitiveKeys []string, hashKey string) *Logger {
 	var handler slog.Handler
 	var logLevel slog.Level
 
@@ -38,6 +64,9 @@ func New(level string, format string) *Logger {
 	} else {
 		handler = slog.NewTextHandler(os.Stdout, opts)
 	}
+	if redact {
+		handler = NewRedactingHandler(handler, sensitiveKeys, hashKey)
+	}
 
 	return &Logger{
 		Logger: slog.New(handler),
@@ -58,15 +87,6 @@ func (l *Logger) WithWorkflow(workflowID string) *Logger {
 
 func (l *Logger) WithContext(ctx context.Context) *Logger {
 	return &Logger{
-		Logger: l.Logger.With("trace_id", getTraceID(ctx)),
-	}
-}
-
-func getTraceID(ctx context.Context) string {
-	if traceID := ctx.Value("trace_id"); traceID != nil {
-		if str, ok := traceID.(string); ok {
-			return str
-		}
+		Logger: l.Logger.With("trace_id", TraceIDFromContext(ctx)),
 	}
-	return ""
 }
@@ -0,0 +1,198 @@
+package logger
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// defaultSensitiveKeys are attribute keys whose values are always masked.
+// Keys are compared lowercased with "_" and "-" removed, so "text_content"
+// and "textContent" both match "textcontent".
+var defaultSensitiveKeys = []string{
+	"token", "secret", "password", "authorization", "cookie", "apikey",
+	"subject", "content", "textcontent", "htmlcontent", "mergefields",
+}
+
+// sensitiveSuffixes mask any key ending in one of them, e.g. "approval_token"
+// or "resend_api_key".
+var sensitiveSuffixes = []string{"token", "secret", "password", "apikey"}
+
+var (
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@([A-Za-z0-9\-]+\.)+[A-Za-z]{2,}`)
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+	bearerPattern = regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/\-]+=*`)
+	keyPattern    = regexp.MustCompile(`\b(re|whsec)_[A-Za-z0-9_+/=]{8,}`)
+)
+
+// RedactingHandler masks personal data and credentials before records reach
+// the wrapped handler. Email addresses keep their domain, with the local
+// part replaced by a hash of the address; tokens and values of sensitive
+// keys become "[redacted:<hash>]". Hashes are an HMAC-SHA256 under hashKey,
+// stable so the same address or token can still be followed across log
+// lines, but not reversible by hashing guessed addresses without the key.
+type RedactingHandler struct {
+	next    slog.Handler
+	keys    map[string]bool
+	hashKey []byte
+}
+
+func NewRedactingHandler(next slog.Handler, sensitiveKeys []string, hashKey string) *RedactingHandler {
+	keys := make(map[string]bool, len(defaultSensitiveKeys)+len(sensitiveKeys))
+	for _, key := range append(append([]string{}, defaultSensitiveKeys...), sensitiveKeys...) {
+		keys[normalizeKey(key)] = true
+	}
+	return &RedactingHandler{next: next, keys: keys, hashKey: []byte(hashKey)}
+}
+
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RedactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, h.redactString(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.redactAttr(attr)
+	}
+	return &RedactingHandler{next: h.next.WithAttrs(redacted), keys: h.keys, hashKey: h.hashKey}
+}
+
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{next: h.next.WithGroup(name), keys: h.keys, hashKey: h.hashKey}
+}
+
+func (h *RedactingHandler) sensitive(key string) bool {
+	normalized := normalizeKey(key)
+	if h.keys[normalized] {
+		return true
+	}
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(normalized, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *RedactingHandler) redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+
+	if h.sensitive(attr.Key) && value.Kind() != slog.KindGroup {
+		if value.Kind() == slog.KindString && value.String() == "" {
+			return attr
+		}
+		return slog.String(attr.Key, h.mask(value.String()))
+	}
+
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, h.redactString(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, a := range group {
+			redacted[i] = h.redactAttr(a)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		return slog.Any(attr.Key, h.redactAny(value.Any()))
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+// redactAny redacts the value types the services log: errors, metadata maps
+// and string slices. Other values are passed through unchanged.
+func (h *RedactingHandler) redactAny(v any) any {
+	switch v := v.(type) {
+	case error:
+		return h.redactString(v.Error())
+	case string:
+		return h.redactString(v)
+	case []string:
+		redacted := make([]string, len(v))
+		for i, s := range v {
+			redacted[i] = h.redactString(s)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = h.redactAny(item)
+		}
+		return redacted
+	case map[string]string:
+		redacted := make(map[string]string, len(v))
+		for k, s := range v {
+			if h.sensitive(k) && s != "" {
+				redacted[k] = h.mask(s)
+			} else {
+				redacted[k] = h.redactString(s)
+			}
+		}
+		return redacted
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			if s, ok := item.(string); ok && s == "" {
+				redacted[k] = s
+			} else if h.sensitive(k) {
+				redacted[k] = h.mask(stringify(item))
+			} else {
+				redacted[k] = h.redactAny(item)
+			}
+		}
+		return redacted
+	}
+	return v
+}
+
+// redactString hashes email addresses and masks tokens and API keys found
+// anywhere in s.
+func (h *RedactingHandler) redactString(s string) string {
+	if s == "" {
+		return s
+	}
+	s = jwtPattern.ReplaceAllStringFunc(s, h.mask)
+	s = bearerPattern.ReplaceAllStringFunc(s, func(m string) string {
+		return "Bearer " + h.mask(m)
+	})
+	s = keyPattern.ReplaceAllStringFunc(s, h.mask)
+	return emailPattern.ReplaceAllStringFunc(s, func(addr string) string {
+		at := strings.LastIndex(addr, "@")
+		return h.hash(strings.ToLower(addr)) + addr[at:]
+	})
+}
+
+func (h *RedactingHandler) mask(s string) string {
+	return "[redacted:" + h.hash(s) + "]"
+}
+
+func (h *RedactingHandler) hash(s string) string {
+	mac := hmac.New(sha256.New, h.hashKey)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil)[:6])
+}
+
+func stringify(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return slog.AnyValue(v).String()
+}
+
+func normalizeKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
@@ -18,6 +18,13 @@ WORKER_PID=$!
 # Wait a moment for worker to start
 sleep 2
 
+# The worker exits at startup when required settings such as
+# INTERNAL_API_TOKEN are missing; its log above says which
+if ! kill -0 $WORKER_PID 2>/dev/null; then
+    echo "❌ Worker exited during startup; see its log above (required settings are listed in config/.env.example)"
+    exit 1
+fi
+
 # Start server in background
 echo "🌐 Starting HTTP server..."
 ./server &
@@ -207,9 +207,11 @@ const requireAdmin = requireRole(["Owner", "Administrator"]);
 // Middleware for manager+ operations
 const requireManagerOrAdmin = requireRole(["Owner", "Administrator", "Manager"]);
 
-const generateTokens = (userId: string, tenantId: string) => {
+// The role goes into the access token so services that verify it on their
+// own (the Go email server) can map it to permissions without a user lookup
+const generateTokens = (userId: string, tenantId: string, role?: string) => {
   const now = Math.floor(Date.now() / 1000); // Current time in seconds
-  const accessToken = jwt.sign({ userId, tenantId, iat: now }, JWT_SECRET, {
+  const accessToken = jwt.sign({ userId, tenantId, ...(role && { role }), iat: now }, JWT_SECRET, {
     expiresIn: ACCESS_TOKEN_EXPIRES,
   });
   const refreshToken = jwt.sign(
@@ -575,6 +577,7 @@ export async function registerRoutes(app: Express): Promise<Server> {
       const { accessToken, refreshToken } = generateTokens(
         user.id,
         user.tenantId,
+        user.role,
       );
 
       // Get device information
@@ -661,7 +664,7 @@ export async function registerRoutes(app: Express): Promise<Server> {
       await storage.verifyUserEmail(user.id, user.tenantId);
 
       // Generate tokens for automatic login
-      const { accessToken, refreshToken } = generateTokens(user.id, user.tenantId);
+      const { accessToken, refreshToken } = generateTokens(user.id, user.tenantId, user.role);
 
       // Store refresh token with device info
       const deviceInfo = getDeviceInfo(req);
@@ -837,22 +840,32 @@ export async function registerRoutes(app: Express): Promise<Server> {
         return res.status(400).json({ message: "reviewerEmail or reviewerId is required" });
       }
 
-      // Build workflow ID to match Go server convention
+      // Build workflow ID to match Go server convention (internal/approval)
       const workflowId = `reviewer-email-workflow-${emailId}`;
 
-      // Sign approval token compatible with Go server
-      const jwtSecret = process.env.JWT_SECRET || "";
-      if (!jwtSecret) {
+      // Sign approval token compatible with the Go server's approval keys
+      const approvalSecret = process.env.APPROVAL_SECRET || process.env.JWT_SECRET || "";
+      if (!approvalSecret) {
         return res.status(500).json({ message: "JWT secret not configured" });
       }
 
+      // Bind the link to the reviewer (sub) with a single-use id (jti)
       const token = jwt.sign(
         {
           emailId,
           workflowId,
+          action: "approve",
+          ...(reviewerId ? { reviewerId } : {}),
+        },
+        approvalSecret,
+        {
+          expiresIn: "7d",
+          subject: toEmail,
+          jwtid: randomBytes(16).toString("hex"),
+          audience: "approve-email",
+          issuer: process.env.APPROVAL_TOKEN_ISSUER || "email-tracking-server",
+          keyid: process.env.APPROVAL_KEY_ID || "v1",
         },
-        jwtSecret,
-        { expiresIn: "7d" },
       );
 
       const approveUrl = `${process.env.GO_EMAIL_SERVER_BASE_URL || "https://tengine.zendwise.work"}/approve-email?token=${encodeURIComponent(token)}`;
@@ -1018,6 +1031,7 @@ export async function registerRoutes(app: Express): Promise<Server> {
       const { accessToken, refreshToken: newRefreshToken } = generateTokens(
         user.id,
         user.tenantId,
+        user.role,
       );
       console.log("🔄 [Server] New tokens generated");
 
@@ -1346,7 +1360,7 @@ export async function registerRoutes(app: Express): Promise<Server> {
       }
 
       // Generate new access token only (keep existing refresh token)
-      const { accessToken } = generateTokens(user.id, user.tenantId);
+      const { accessToken } = generateTokens(user.id, user.tenantId, user.role);
       
       // Update session last used time
       await storage.updateSessionLastUsed(refreshToken);